@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rcArg is one argument from a .bazelrc line such as "build:ci --keep_going".
+type rcArg struct {
+	file    string
+	line    int
+	command string
+	config  string
+	arg     string
+}
+
+func (a rcArg) position() string {
+	return fmt.Sprintf("%s:%d", a.file, a.line)
+}
+
+// parseBazelrc reads a .bazelrc file and the files it imports. %workspace% in
+// import paths is replaced with workspace.
+func parseBazelrc(path, workspace string) ([]rcArg, error) {
+	return parseBazelrcFile(path, workspace, map[string]bool{})
+}
+
+func parseBazelrcFile(path, workspace string, seen map[string]bool) ([]rcArg, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if seen[abs] {
+		return nil, fmt.Errorf("%s: import cycle", path)
+	}
+	seen[abs] = true
+	defer delete(seen, abs)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	args := []rcArg{}
+	scanner := bufio.NewScanner(f)
+	lineNo, startLine := 0, 0
+	var line strings.Builder
+	for scanner.Scan() {
+		lineNo++
+		if line.Len() == 0 {
+			startLine = lineNo
+		}
+		text := scanner.Text()
+		// A trailing backslash continues the line.
+		if strings.HasSuffix(text, "\\") {
+			line.WriteString(strings.TrimSuffix(text, "\\"))
+			line.WriteString(" ")
+			continue
+		}
+		line.WriteString(text)
+		words, err := splitBazelrcLine(line.String())
+		line.Reset()
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, startLine, err)
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		switch words[0] {
+		case "import", "try-import":
+			if len(words) != 2 {
+				return nil, fmt.Errorf("%s:%d: %s expects one path", path, startLine, words[0])
+			}
+			imported := strings.ReplaceAll(words[1], "%workspace%", workspace)
+			if _, err := os.Stat(imported); err != nil && words[0] == "try-import" {
+				continue
+			}
+			more, err := parseBazelrcFile(imported, workspace, seen)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, startLine, err)
+			}
+			args = append(args, more...)
+			continue
+		}
+
+		command, config, _ := strings.Cut(words[0], ":")
+		for _, w := range words[1:] {
+			args = append(args, rcArg{file: path, line: startLine, command: command, config: config, arg: w})
+		}
+	}
+	return args, scanner.Err()
+}
+
+// splitBazelrcLine splits a line into words the way Bazel does: on whitespace,
+// honoring single and double quotes and backslash escapes, and stopping at a
+// '#' that starts a word.
+func splitBazelrcLine(line string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == '#' && !inWord:
+			return words, nil
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitBazelrcLine(t *testing.T) {
+	for _, test := range []struct {
+		line string
+		want []string
+	}{
+		{"build --keep_going  --jobs=4", []string{"build", "--keep_going", "--jobs=4"}},
+		{`build --copt="-DNAME=a b" --host_copt='-O2'`, []string{"build", "--copt=-DNAME=a b", "--host_copt=-O2"}},
+		{`test --test_arg=a\ b`, []string{"test", "--test_arg=a b"}},
+		{"build --define=x=#1 # trailing comment", []string{"build", "--define=x=#1"}},
+		{"# a comment", []string{}},
+		{"", []string{}},
+	} {
+		got, err := splitBazelrcLine(test.line)
+		if err != nil {
+			t.Errorf("splitBazelrcLine(%q): %s", test.line, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitBazelrcLine(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+	if _, err := splitBazelrcLine(`build --copt="-O2`); err == nil {
+		t.Error("splitBazelrcLine() accepted an unterminated quote")
+	}
+}
+
+func TestParseBazelrc(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".bazelrc": "common --enable_bzlmod\n" +
+			"build:ci --keep_going \\\n  --jobs=4\n" +
+			"import %workspace%/tools/shared.bazelrc\n" +
+			"try-import %workspace%/user.bazelrc\n" +
+			"test --copt -O2\n",
+		"tools/shared.bazelrc": "build --nolegacy_external_runfiles\n",
+	})
+	rc := filepath.Join(dir, ".bazelrc")
+	args, err := parseBazelrc(rc, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := filepath.Join(dir, "tools/shared.bazelrc")
+	want := []rcArg{
+		{rc, 1, "common", "", "--enable_bzlmod"},
+		{rc, 2, "build", "ci", "--keep_going"},
+		{rc, 2, "build", "ci", "--jobs=4"},
+		{shared, 1, "build", "", "--nolegacy_external_runfiles"},
+		{rc, 6, "test", "", "--copt"},
+		{rc, 6, "test", "", "-O2"},
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("parseBazelrc() =\n%+v\nwant\n%+v", args, want)
+	}
+
+	for name, files := range map[string]map[string]string{
+		"cycle":          {"a.bazelrc": "import %workspace%/b.bazelrc\n", "b.bazelrc": "import %workspace%/a.bazelrc\n"},
+		"missing import": {"a.bazelrc": "import %workspace%/missing.bazelrc\n"},
+		"bad import":     {"a.bazelrc": "import\n"},
+	} {
+		dir := t.TempDir()
+		writeFiles(t, dir, files)
+		if _, err := parseBazelrc(filepath.Join(dir, "a.bazelrc"), dir); err == nil || !strings.Contains(err.Error(), "a.bazelrc:1") {
+			t.Errorf("%s: parseBazelrc() error = %v, want one at a.bazelrc:1", name, err)
+		}
+	}
+}
@@ -22,10 +22,12 @@ function build_docset {
 
     pushd "versions/$version"
 
-    go run ../../dashing.go build \
+    # The page text index of default_fts_enabled needs SQLite's FTS5 module.
+    go run -tags sqlite_fts5 "$repo_root" build \
         --config "$repo_root/config.yaml" \
         --output "$output_dir/bazel.docset" \
-        --version "$version"
+        --version "$version" \
+        --export-flags json,csv
 
     tar --exclude='.DS_Store' -cvzf $output_dir/bazel.tgz -C $output_dir bazel.docset
 
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// BuildLanguage configures reading rule classes and their attributes from the
+// output of `bazel info build-language` (a binary BuildLanguage proto).
+// Entries link to the anchors the Build Encyclopedia pages already have.
+type BuildLanguage struct {
+	// File is the saved output of `bazel info build-language`.
+	File string `yaml:"file" expand:"file"`
+	// MatchPath selects the pages that document rules. Defaults to the Build
+	// Encyclopedia, .*/reference/be/.*
+	MatchPath *regexpYaml `yaml:"matchpath"`
+	// RuleType and AttributeType are the entry types to use. They may use
+	// type_aliases and default to Class and Attribute.
+	RuleType      string `yaml:"rule_type"`
+	AttributeType string `yaml:"attribute_type"`
+
+	rules []buildRule
+}
+
+type buildRule struct {
+	name, documentation string
+	attributes          []buildAttribute
+}
+
+type buildAttribute struct {
+	name, documentation, typ, defaultValue string
+	mandatory                              bool
+}
+
+// defaultBuildLanguageMatchPath selects the Build Encyclopedia pages.
+var defaultBuildLanguageMatchPath = regexp.MustCompile(`.*/reference/be/.*`)
+
+// buildAttributeTypes are the names of Bazel's Attribute.Discriminator values.
+var buildAttributeTypes = map[uint64]string{
+	1: "integer", 2: "string", 3: "label", 4: "output", 5: "string_list",
+	6: "label_list", 7: "output_list", 8: "distribution_set", 9: "license",
+	10: "string_dict", 11: "fileset_entry_list", 12: "label_list_dict",
+	13: "string_list_dict", 14: "boolean", 15: "tristate", 16: "integer_list",
+	17: "string_dict_unary", 18: "unknown", 19: "label_dict_unary",
+	20: "selector_list", 21: "label_keyed_string_dict",
+}
+
+func (b *BuildLanguage) enabled() bool {
+	return len(b.File) > 0
+}
+
+// resolveTypes fills in the default types, applies type_aliases and checks
+// that both are types Dash supports.
+func (b *BuildLanguage) resolveTypes(d *Dashing) error {
+	if !b.enabled() {
+		return nil
+	}
+	if len(b.RuleType) == 0 {
+		b.RuleType = "Class"
+	}
+	if len(b.AttributeType) == 0 {
+		b.AttributeType = "Attribute"
+	}
+	b.RuleType, b.AttributeType = d.resolveType(b.RuleType), d.resolveType(b.AttributeType)
+	for _, t := range []string{b.RuleType, b.AttributeType} {
+		if !isDashEntryType(t) {
+			return fmt.Errorf("build_language: %s", unknownType(t))
+		}
+	}
+	return nil
+}
+
+// load reads the BuildLanguage proto. Rules and attributes whose names aren't
+// valid in BUILD files (implicit and private ones) are left out.
+func (b *BuildLanguage) load() error {
+	data, err := os.ReadFile(b.File)
+	if err != nil {
+		return err
+	}
+	f, err := decodeProtoFields(data)
+	if err != nil {
+		return fmt.Errorf("invalid BuildLanguage proto: %w", err)
+	}
+
+	var errs []string
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	collect(f.each(1, func(r protoFields) {
+		rule := buildRule{name: r.string(1), documentation: r.string(3)}
+		if !isBuildIdentifier(rule.name) {
+			return
+		}
+		collect(r.each(2, func(a protoFields) {
+			attr := buildAttribute{
+				name:          a.string(1),
+				documentation: a.string(5),
+				mandatory:     a.bool(3),
+			}
+			if t := a.varints[2]; len(t) > 0 {
+				attr.typ = buildAttributeTypes[t[len(t)-1]]
+			}
+			if len(a.bytes[9]) > 0 {
+				v, err := decodeProtoFields(a.bytes[9][0])
+				collect(err)
+				attr.defaultValue = buildAttributeValue(v)
+			}
+			if isBuildIdentifier(attr.name) {
+				rule.attributes = append(rule.attributes, attr)
+			}
+		}))
+		b.rules = append(b.rules, rule)
+	}))
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid BuildLanguage proto: %s", strings.Join(errs, "; "))
+	}
+	fmt.Printf("Read %d rules from %s\n", len(b.rules), b.File)
+	return nil
+}
+
+func isBuildIdentifier(name string) bool {
+	return len(name) > 0 && unicode.IsLetter(rune(name[0]))
+}
+
+// buildAttributeValue formats an AttributeValue (int = 1, string = 2,
+// bool = 3, list = 4, dict = 5) the way it would be written in a BUILD file.
+func buildAttributeValue(v protoFields) string {
+	switch {
+	case len(v.bytes[2]) > 0:
+		return strconv.Quote(v.string(2))
+	case len(v.varints[3]) > 0:
+		if v.bool(3) {
+			return "True"
+		}
+		return "False"
+	case len(v.varints[1]) > 0:
+		return strconv.FormatInt(int64(int32(v.varints[1][0])), 10)
+	case len(v.bytes[4]) > 0:
+		items := []string{}
+		v.each(4, func(item protoFields) {
+			items = append(items, buildAttributeValue(item))
+		})
+		return "[" + strings.Join(items, ", ") + "]"
+	case len(v.bytes[5]) > 0:
+		items := []string{}
+		v.each(5, func(entry protoFields) {
+			value := ""
+			if len(entry.bytes[2]) > 0 {
+				if sub, err := decodeProtoFields(entry.bytes[2][0]); err == nil {
+					value = buildAttributeValue(sub)
+				}
+			}
+			items = append(items, strconv.Quote(entry.string(1))+": "+value)
+		})
+		sort.Strings(items)
+		return "{" + strings.Join(items, ", ") + "}"
+	}
+	return ""
+}
+
+func (a buildAttribute) description() string {
+	parts := []string{a.typ}
+	if a.mandatory {
+		parts = append(parts, "mandatory")
+	} else if len(a.defaultValue) > 0 {
+		parts = append(parts, "default "+a.defaultValue)
+	}
+	description := strings.Join(parts, "; ")
+	if doc := htmlText(a.documentation); len(doc) > 0 {
+		description += ". " + doc
+	}
+	return truncate(description, maxDescriptionLength)
+}
+
+// htmlText returns the text of an HTML fragment with whitespace collapsed.
+func htmlText(fragment string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{Type: html.ElementNode, DataAtom: atom.Div, Data: "div"})
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		} else {
+			b.WriteString(text(n))
+		}
+	}
+	return strings.TrimSpace(whitespaceRegexp.ReplaceAllString(b.String(), " "))
+}
+
+// refs finds the rules documented on a page by their anchors: "<rule>" for
+// the rule and "<rule>.<attribute>" for each attribute. Attributes without an
+// anchor of their own link to their rule. Anchored elements are added to
+// claimed.
+func (b *BuildLanguage) refs(dashing Dashing, top *html.Node, filepath string, headNode *html.Node, claimed map[*html.Node]bool) []*reference {
+	matchPath := defaultBuildLanguageMatchPath
+	if b.MatchPath != nil {
+		matchPath = b.MatchPath.Regexp
+	}
+	if !b.enabled() || !matchPath.MatchString(filepath) {
+		return nil
+	}
+
+	ids := map[string]*html.Node{}
+	for n := range top.Descendants() {
+		if id := attr(n, "id"); n.Type == html.ElementNode && len(id) > 0 {
+			if _, ok := ids[id]; !ok {
+				ids[id] = n
+			}
+		}
+	}
+
+	refs := []*reference{}
+	pageTitle := dashing.pageTitle(top)
+	add := func(n *html.Node, name, etype, id, description string, tocLevel int) {
+		refs = append(refs, &reference{
+			selector:    "build_language",
+			source:      "build_language",
+			name:        name,
+			etype:       etype,
+			href:        filepath + "#" + id,
+			pageTitle:   pageTitle,
+			description: description,
+		})
+		claimed[n] = true
+		tocAnchor, linkNode := tocAnchorAndLinkNode(name, etype, tocLevel)
+		headNode.AppendChild(linkNode)
+		n.Parent.InsertBefore(tocAnchor, n)
+	}
+
+	for _, rule := range b.rules {
+		ruleNode, ok := ids[rule.name]
+		if !ok {
+			continue
+		}
+		add(ruleNode, rule.name, b.RuleType, rule.name, truncate(htmlText(rule.documentation), maxDescriptionLength), 1)
+		for _, a := range rule.attributes {
+			id := rule.name + "." + a.name
+			if n, ok := ids[id]; ok {
+				add(n, id, b.AttributeType, id, a.description(), 0)
+			} else {
+				refs = append(refs, &reference{
+					selector:    "build_language",
+					source:      "build_language",
+					name:        id,
+					etype:       b.AttributeType,
+					href:        filepath + "#" + rule.name,
+					pageTitle:   pageTitle,
+					description: a.description(),
+				})
+			}
+		}
+	}
+	return refs
+}
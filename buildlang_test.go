@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	css "github.com/andybalholm/cascadia"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// AttributeValue from src/main/protobuf/build.proto.
+func intValue(i int32) []byte     { return protoVarint(nil, 1, uint64(int64(i))) }
+func stringValue(s string) []byte { return protoString(nil, 2, s) }
+func boolValue(v bool) []byte     { return protoVarint(nil, 3, protowire.EncodeBool(v)) }
+func listValue(items ...[]byte) []byte {
+	var b []byte
+	for _, item := range items {
+		b = protoMessage(b, 4, item)
+	}
+	return b
+}
+func dictValue(kv ...interface{}) []byte {
+	var b []byte
+	for i := 0; i < len(kv); i += 2 {
+		entry := protoString(nil, 1, kv[i].(string))
+		entry = protoMessage(entry, 2, kv[i+1].([]byte))
+		b = protoMessage(b, 5, entry)
+	}
+	return b
+}
+
+// attributeDefinition writes an AttributeDefinition: name = 1, type = 2,
+// mandatory = 3, documentation = 5, default = 9 and the allow_empty (6) and
+// nodep (12) fields real output sets but dashing ignores.
+func attributeDefinition(name string, typ uint64, mandatory bool, doc string, def []byte) []byte {
+	b := protoString(nil, 1, name)
+	b = protoVarint(b, 2, typ)
+	b = protoVarint(b, 3, protowire.EncodeBool(mandatory))
+	if len(doc) > 0 {
+		b = protoString(b, 5, doc)
+	}
+	b = protoVarint(b, 6, 1)
+	if def != nil {
+		b = protoMessage(b, 9, def)
+	}
+	return protoVarint(b, 12, 0)
+}
+
+// buildLanguageFixture mirrors an excerpt of `bazel info build-language`:
+// cc_library with a few of its attributes, including implicit ones, and the
+// private rule classes the output also lists.
+func buildLanguageFixture() []byte {
+	ccLibrary := protoString(nil, 1, "cc_library")
+	for _, a := range [][]byte{
+		attributeDefinition("name", 2, true, "", nil),
+		attributeDefinition("srcs", 6, false, "The list of C and C++ files.", listValue()),
+		attributeDefinition("copts", 5, false, "Add these options to the <code>C++</code> compilation command.", listValue(stringValue("-Wall"), stringValue("-O2"))),
+		attributeDefinition("linkstatic", 14, false, "", boolValue(false)),
+		attributeDefinition("alwayslink", 14, false, "", boolValue(true)),
+		attributeDefinition("stamp", 15, false, "", intValue(-1)),
+		attributeDefinition("strip_include_prefix", 2, false, "", stringValue("")),
+		attributeDefinition("local_defines", 13, false, "", dictValue("b", listValue(stringValue("2")), "a", listValue(stringValue("1")))),
+		attributeDefinition("$cc_toolchain", 3, false, "", stringValue("@bazel_tools//tools/cpp:current_cc_toolchain")),
+		attributeDefinition(":cc_toolchain", 3, false, "", nil),
+	} {
+		ccLibrary = protoMessage(ccLibrary, 2, a)
+	}
+	ccLibrary = protoString(ccLibrary, 3, "<p>Use <code>cc_library()</code> for C++-compiled libraries.</p>")
+	ccLibrary = protoString(ccLibrary, 4, "//tools/build_rules:cc_library")
+
+	b := protoMessage(nil, 1, ccLibrary)
+	b = protoMessage(b, 1, protoString(nil, 1, "$base_rule"))
+	return protoMessage(b, 1, protoString(nil, 1, "_internal_rule"))
+}
+
+func TestBuildLanguageLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "build-language.pb")
+	if err := os.WriteFile(file, buildLanguageFixture(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := BuildLanguage{File: file}
+	if err := b.load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.rules) != 1 || b.rules[0].name != "cc_library" {
+		t.Fatalf("rules = %+v, want only cc_library", b.rules)
+	}
+	rule := b.rules[0]
+	if got, want := htmlText(rule.documentation), "Use cc_library() for C++-compiled libraries."; got != want {
+		t.Errorf("documentation = %q, want %q", got, want)
+	}
+
+	want := []buildAttribute{
+		{name: "name", typ: "string", mandatory: true},
+		{name: "srcs", typ: "label_list", documentation: "The list of C and C++ files."},
+		{name: "copts", typ: "string_list", documentation: "Add these options to the <code>C++</code> compilation command.", defaultValue: `["-Wall", "-O2"]`},
+		{name: "linkstatic", typ: "boolean", defaultValue: "False"},
+		{name: "alwayslink", typ: "boolean", defaultValue: "True"},
+		{name: "stamp", typ: "tristate", defaultValue: "-1"},
+		{name: "strip_include_prefix", typ: "string", defaultValue: `""`},
+		{name: "local_defines", typ: "string_list_dict", defaultValue: `{"a": ["1"], "b": ["2"]}`},
+	}
+	if !reflect.DeepEqual(rule.attributes, want) {
+		t.Errorf("attributes =\n%+v\nwant\n%+v", rule.attributes, want)
+	}
+}
+
+func TestBuildAttributeDescription(t *testing.T) {
+	for _, test := range []struct {
+		attr buildAttribute
+		want string
+	}{
+		{buildAttribute{typ: "label_list", mandatory: true, defaultValue: "[]"}, "label_list; mandatory"},
+		{buildAttribute{typ: "string_list", defaultValue: `["-Wall"]`, documentation: "Add <code>copts</code>."}, `string_list; default ["-Wall"]. Add copts.`},
+		{buildAttribute{typ: "boolean"}, "boolean"},
+	} {
+		if got := test.attr.description(); got != test.want {
+			t.Errorf("%+v: description() = %q, want %q", test.attr, got, test.want)
+		}
+	}
+}
+
+func TestBuildLanguageLoadInvalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "build-language.pb")
+	truncated := buildLanguageFixture()
+	if err := os.WriteFile(file, truncated[:len(truncated)-3], 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := BuildLanguage{File: file}
+	if err := b.load(); err == nil {
+		t.Error("load() of a truncated proto succeeded")
+	}
+}
+
+func TestBuildLanguagePageRefs(t *testing.T) {
+	const page = `<html><head></head><body>
+<h2 id="cc_library">cc_library</h2><p>Builds a library.</p>
+<table><tr><td id="cc_library.srcs">srcs</td></tr></table>
+<h2 id="usage">Usage</h2>
+</body></html>`
+	d := Dashing{
+		Selectors: []Transform{{CssSelector: *testSelector("h2"), Type: "Class"}},
+		BuildLanguage: BuildLanguage{
+			File: "build-language.pb", RuleType: "Class", AttributeType: "Attribute",
+			rules: []buildRule{{name: "cc_library", attributes: []buildAttribute{{name: "srcs", typ: "label_list"}}}},
+		},
+	}
+	for _, test := range []struct {
+		path    string
+		want    []string
+		anchors int
+	}{
+		// The h2 selector skips the heading build_language anchored.
+		{"docs/reference/be/c-cpp.html", []string{"Class cc_library", "Attribute cc_library.srcs", "Class Usage"}, 2},
+		// Guide pages aren't matched by default, even with an anchor named
+		// like a rule.
+		{"docs/tutorials/cpp.html", []string{"Class cc_library", "Class Usage"}, 1},
+	} {
+		top := parseTestHTML(t, page)
+		head := css.MustCompile("head").MatchFirst(top)
+		var got []string
+		for _, ref := range d.pageRefs(top, test.path, head) {
+			got = append(got, ref.etype+" "+ref.name)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: pageRefs() = %q, want %q", test.path, got, test.want)
+		}
+		if n := len(css.MustCompile(`a.dashAnchor[name*="cc_library"]`).MatchAll(top)); n != test.anchors {
+			t.Errorf("%s: %d TOC anchors for cc_library, want %d", test.path, n, test.anchors)
+		}
+	}
+}
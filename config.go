@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readConfig reads the configuration file at cf as a YAML map, with the
+// files it extends merged in underneath it and the overrides under
+// `versions` applied for version.
+//
+// `extends` names one or more files, relative to cf, whose settings cf
+// builds on. Maps are merged key by key and everything else is replaced,
+// except that a key ending in "+" appends to the list it names, e.g.
+// `selectors+:`.
+//
+// `versions` maps semver ranges such as ">=7.0.0 <7.4.0" or "6.x || 7.0.x"
+// to settings merged over the rest of the file, in order, when version
+// matches. A file's overrides are applied before the files extending it are
+// merged over it.
+func readConfig(cf, version string) (map[string]interface{}, error) {
+	return readConfigFile(cf, version, map[string]bool{})
+}
+
+// readConfigFile reads cf with the files it extends merged in and its
+// version overrides applied. seen guards against files that extend each
+// other.
+func readConfigFile(cf, version string, seen map[string]bool) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(cf)
+	if err != nil {
+		return nil, err
+	}
+	if seen[abs] {
+		return nil, fmt.Errorf("%s extends itself, directly or through other files", cf)
+	}
+	seen[abs] = true
+	defer delete(seen, abs)
+
+	conf, err := os.ReadFile(cf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open configuration file '%s': %w (Run `dashing init`?)", cf, err)
+	}
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal(conf, &config); err != nil {
+		return nil, fmt.Errorf("%s: failed to parse YAML: %w", cf, err)
+	}
+	// Decode versions again as a node to keep the order of its ranges.
+	var doc struct {
+		Versions yaml.Node `yaml:"versions"`
+	}
+	if err := yaml.Unmarshal(conf, &doc); err != nil {
+		return nil, fmt.Errorf("%s: failed to parse YAML: %w", cf, err)
+	}
+	delete(config, "versions")
+
+	var parents []string
+	switch extends := config["extends"].(type) {
+	case nil:
+	case string:
+		parents = []string{extends}
+	case []interface{}:
+		for _, p := range extends {
+			s, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: extends must be a file name or a list of file names", cf)
+			}
+			parents = append(parents, s)
+		}
+	default:
+		return nil, fmt.Errorf("%s: extends must be a file name or a list of file names", cf)
+	}
+	delete(config, "extends")
+
+	base := map[string]interface{}{}
+	for _, p := range parents {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(cf), p)
+		}
+		parent, err := readConfigFile(p, version, seen)
+		if err != nil {
+			return nil, err
+		}
+		base = mergeConfig(base, parent)
+	}
+	return applyVersions(cf, version, mergeConfig(base, config), doc.Versions)
+}
+
+// applyVersions merges the overrides in versions, the `versions` map of cf,
+// whose ranges match version over config.
+func applyVersions(cf, version string, config map[string]interface{}, versions yaml.Node) (map[string]interface{}, error) {
+	if versions.Kind == 0 {
+		return config, nil
+	}
+	if versions.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: versions must map version ranges to settings", cf)
+	}
+	for i := 0; i+1 < len(versions.Content); i += 2 {
+		constraint := versions.Content[i].Value
+		matches, err := versionMatches(version, constraint)
+		if err != nil {
+			return nil, fmt.Errorf("%s: versions: %w", cf, err)
+		}
+		if !matches {
+			continue
+		}
+		var overrides map[string]interface{}
+		if err := versions.Content[i+1].Decode(&overrides); err != nil {
+			return nil, fmt.Errorf("%s: versions: '%s': %w", cf, constraint, err)
+		}
+		fmt.Printf("Applying %s overrides for version %s (%s)\n", cf, version, constraint)
+		config = mergeConfig(config, overrides)
+	}
+	return config, nil
+}
+
+// mergeConfig merges override into base and returns the result. Nested maps
+// are merged, a key ending in "+" appends its list to the base list, and
+// anything else replaces the base value. Replacements are applied before
+// appends, so "selectors" and "selectors+" in one override replace the list
+// and then add to it.
+func mergeConfig(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		merged[k] = v
+	}
+	keys := make([]string, 0, len(override))
+	for k := range override {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		iAppend, jAppend := strings.HasSuffix(keys[i], "+"), strings.HasSuffix(keys[j], "+")
+		if iAppend != jAppend {
+			return jAppend
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys {
+		v := override[k]
+		if key, ok := strings.CutSuffix(k, "+"); ok {
+			existing, _ := merged[key].([]interface{})
+			if list, ok := v.([]interface{}); ok {
+				merged[key] = append(append([]interface{}{}, existing...), list...)
+				continue
+			}
+			k = key
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			if b, ok := merged[k].(map[string]interface{}); ok {
+				merged[k] = mergeConfig(b, m)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want semver
+		ok   bool
+	}{
+		{"7.4.0", semver{7, 4, 0}, true},
+		{"v8", semver{8, -1, -1}, true},
+		{"7.x", semver{7, -1, -1}, true},
+		{"7.*.1", semver{7, -1, 1}, true},
+		{"8.0.0rc2", semver{8, 0, 0}, true},
+		{"8.0rc2", semver{-1, -1, -1}, false},
+		{"latest", semver{-1, -1, -1}, false},
+		{"", semver{-1, -1, -1}, false},
+	} {
+		got, ok := parseSemver(test.s)
+		if ok != test.ok || (ok && got != test.want) {
+			t.Errorf("parseSemver(%q) = %v, %v, want %v, %v", test.s, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestVersionMatches(t *testing.T) {
+	for _, test := range []struct {
+		version, constraint string
+		want                bool
+	}{
+		{"7.2.0", ">=7.0.0 <7.4.0", true},
+		{"7.4.0", ">=7.0.0 <7.4.0", false},
+		{"7.4.0", ">=7.0.0, <=7.4.0", true},
+		{"6.5.0", "6.x || 7.0.x", true},
+		{"7.0.2", "6.x || 7.0.x", true},
+		{"7.1.0", "6.x || 7.0.x", false},
+		{"7.2.0", "7", true},
+		{"7", "7.0.0", false},
+		{"7.2.3", "~7.2.1", true},
+		{"7.3.0", "~7.2.1", false},
+		{"7.9.0", "^7.2.1", true},
+		{"8.0.0", "^7.2.1", false},
+		{"7.2.0", "!=7.2.x", false},
+		{"8.0.0rc2", ">7.9.9", true},
+		{"latest", "*", true},
+		{"latest", "latest", true},
+		{"latest", ">=7.0.0", false},
+	} {
+		got, err := versionMatches(test.version, test.constraint)
+		if err != nil {
+			t.Errorf("versionMatches(%q, %q): %v", test.version, test.constraint, err)
+		} else if got != test.want {
+			t.Errorf("versionMatches(%q, %q) = %v, want %v", test.version, test.constraint, got, test.want)
+		}
+	}
+
+	for _, constraint := range []string{"7.0.0 ||", ">=seven", "=>7.0.0"} {
+		if _, err := versionMatches("7.0.0", constraint); err == nil {
+			t.Errorf("versionMatches(7.0.0, %q) succeeded, want an error", constraint)
+		}
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	base := map[string]interface{}{
+		"name":      "Bazel",
+		"selectors": []interface{}{"h1", "h2"},
+		"dedupe": map[string]interface{}{
+			"same_name": true,
+			"nested":    map[string]interface{}{"a": 1, "b": 2},
+		},
+		"remove_elements": []interface{}{".devsite-actions"},
+	}
+	override := map[string]interface{}{
+		"name":       "Bazel 7",
+		"selectors+": []interface{}{"h3"},
+		"dedupe": map[string]interface{}{
+			"same_anchor": true,
+			"nested":      map[string]interface{}{"b": 3},
+		},
+		"remove_elements": []interface{}{"devsite-feedback"},
+		"ignore+":         []interface{}{"404.html"},
+	}
+	want := map[string]interface{}{
+		"name":      "Bazel 7",
+		"selectors": []interface{}{"h1", "h2", "h3"},
+		"dedupe": map[string]interface{}{
+			"same_name":   true,
+			"same_anchor": true,
+			"nested":      map[string]interface{}{"a": 1, "b": 3},
+		},
+		"remove_elements": []interface{}{"devsite-feedback"},
+		"ignore":          []interface{}{"404.html"},
+	}
+	if got := mergeConfig(base, override); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeConfig() =\n%v\nwant\n%v", got, want)
+	}
+	if got := base["selectors"].([]interface{}); len(got) != 2 {
+		t.Errorf("mergeConfig() changed the base list to %v", got)
+	}
+}
+
+func TestMergeConfigReplacesBeforeAppending(t *testing.T) {
+	base := map[string]interface{}{"selectors": []interface{}{"h1"}}
+	override := map[string]interface{}{
+		"selectors+": []interface{}{"h3"},
+		"selectors":  []interface{}{"h2"},
+	}
+	want := []interface{}{"h2", "h3"}
+	// Map order varies between runs; the result mustn't.
+	for i := 0; i < 20; i++ {
+		if got := mergeConfig(base, override)["selectors"]; !reflect.DeepEqual(got, want) {
+			t.Fatalf("mergeConfig() selectors = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestReadConfigVersionsInExtendedFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("base.yaml", `
+name: Bazel
+index: about.html
+selectors: [h1]
+versions:
+  "<7.0.0":
+    index: old.html
+    selectors+: [h6]
+`)
+	write("child.yaml", `
+extends: base.yaml
+selectors+: [h2]
+versions:
+  "6.x":
+    name: Bazel 6
+`)
+
+	for version, want := range map[string]map[string]interface{}{
+		"6.5.0": {"name": "Bazel 6", "index": "old.html", "selectors": []interface{}{"h1", "h6", "h2"}},
+		"8.0.0": {"name": "Bazel", "index": "about.html", "selectors": []interface{}{"h1", "h2"}},
+	} {
+		got, err := readConfig(filepath.Join(dir, "child.yaml"), version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("readConfig(child.yaml, %s) =\n%v\nwant\n%v", version, got, want)
+		}
+	}
+}
@@ -10,8 +10,11 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"text/template"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/andybalholm/cascadia"
 	css "github.com/andybalholm/cascadia"
@@ -21,31 +24,9 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 	"gopkg.in/yaml.v3"
+	"howett.net/plist"
 )
 
-const plist = `<?xml version="1.0" encoding="UTF-8"?>
-<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
-<plist version="1.0">
-<dict>
-	<key>CFBundleIdentifier</key>
-	<string>{{.Name}}</string>
-	<key>CFBundleName</key>
-	<string>{{.FancyName}}</string>
-	<key>DocSetPlatformFamily</key>
-	<string>{{.Name}}</string>
-	<key>isDashDocset</key>
-	<true/>
-	<key>DashDocSetFamily</key>
-	<string>dashtoc3</string>
-	<key>dashIndexFilePath</key>
-	<string>{{.Index}}</string>
-	<key>isJavaScriptEnabled</key><{{.AllowJS}}/>{{if .ExternalURL}}
-	<key>DashDocSetFallbackURL</key>
-	<string>{{.ExternalURL}}</string>{{end}}
-</dict>
-</plist>
-`
-
 type Dashing struct {
 	// The human-oriented name of the package.
 	Name string `yaml:"name"`
@@ -57,6 +38,8 @@ type Dashing struct {
 	Selectors []Transform `yaml:"selectors"`
 	// BackupSelectors - Used if none of the selectors match
 	BackupSelectors []Transform `yaml:"backup_selectors"`
+	// TypeAliases maps names usable as a selector type to Dash entry types.
+	TypeAliases map[string]string `yaml:"type_aliases"`
 	// IgnorePathRegexes will cause any html file that matches from being pulled into the docs
 	IgnorePathRegexes []*regexp.Regexp `yaml:"ignore_path_regexes"`
 	// WalkRoot is the directory to start walking from.
@@ -72,13 +55,61 @@ type Dashing struct {
 	CssSelectorForBody *cssSelectorYaml `yaml:"css_selector_for_body"`
 	// A css selector for the title of the page.
 	CssSelectorForTitle *cssSelectorYaml `yaml:"css_selector_for_title"`
-	// A 32x32 pixel PNG image.
-	Icon32x32 string `yaml:"icon32x32"`
+	// TitleRewrites are applied, in order, to the text of each page's <title>.
+	TitleRewrites []TitleRewrite `yaml:"title_rewrites"`
+	// TitleTemplate is evaluated after TitleRewrites to produce the final
+	// <title>. It can use .Title, .Version, .Name and .Package.
+	TitleTemplate *templateYaml `yaml:"title_template"`
+	// Dedupe merges entries on the same page that describe the same thing.
+	Dedupe Dedupe `yaml:"dedupe"`
+	// Markdown configures rendering Markdown sources into pages.
+	Markdown Markdown `yaml:"markdown"`
+	// Stardoc configures reading Stardoc output for rules_* repositories.
+	Stardoc Stardoc `yaml:"stardoc"`
+	// BuildLanguage adds rules and attributes from `bazel info build-language`.
+	BuildLanguage BuildLanguage `yaml:"build_language"`
+	// FlagExport extracts command-line flags into structured data.
+	FlagExport FlagExport `yaml:"flag_export"`
+	// DuplicatePages finds pages that are copies of another page.
+	DuplicatePages DuplicatePages `yaml:"duplicate_pages"`
+	// Sources are built into one docset instead of WalkRoot.
+	Sources []Source `yaml:"sources"`
+	// A 32x32 pixel PNG image. Other sizes, ICO and SVG files are converted.
+	Icon32x32 string `yaml:"icon32x32" expand:"file"`
+	// A 64x64 pixel PNG image, used as icon@2x.png on Retina displays.
+	// Defaults to icon32x32 if that is big enough.
+	Icon64x64 string `yaml:"icon64x64" expand:"file"`
 	AllowJS   bool   `yaml:"allowJS"`
 	// External URL for "Open Online Page"
 	ExternalURL string `yaml:"externalURL"`
+	// Keyword is the search keyword that limits a Dash search to the docset.
+	Keyword string `yaml:"keyword"`
+	// PluginKeyword is the keyword editor plugins use to pick the docset.
+	PluginKeyword string `yaml:"plugin_keyword"`
+	// WebSearchKeyword is the keyword for searching the web from Dash.
+	WebSearchKeyword string `yaml:"web_search_keyword"`
+	// PlayURL is an online playground linked from the docset.
+	PlayURL string `yaml:"play_url"`
+	// DefaultFTSEnabled turns on full-text search when the docset is
+	// installed, and indexes page text for `query --text`.
+	DefaultFTSEnabled bool `yaml:"default_fts_enabled"`
+	// InfoPlist sets any other Info.plist keys. It wins over the settings above.
+	InfoPlist map[string]interface{} `yaml:"info_plist"`
+	// Revision goes in Zeal's meta.json. Bump it to make Zeal pick up a
+	// rebuilt docset of the same version.
+	Revision string `yaml:"revision"`
 
 	docsetVersion string
+	// configDir is the directory of the configuration file.
+	configDir string
+	// duplicates maps each duplicate page to its canonical page.
+	duplicates map[string]string
+	// source is the source being built, if the config has any.
+	source *Source
+	// profile is the output profile from `build --profile`.
+	profile string
+	// pageText is true if the docset has a pageText table to index pages in.
+	pageText bool
 }
 
 func (d *Dashing) shouldIgnoreFile(src string) bool {
@@ -120,19 +151,50 @@ func (r *regexpYaml) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+// templateYaml is a custom type that embeds *template.Template and implements UnmarshalYAML.
+type templateYaml struct {
+	*template.Template
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface for templateYaml.
+func (t *templateYaml) UnmarshalYAML(value *yaml.Node) error {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(value.Value)
+	if err != nil {
+		return fmt.Errorf("invalid template '%s': %w", value.Value, err)
+	}
+	t.Template = tmpl
+	return nil
+}
+
+// TitleRewrite replaces every match of Regex in a page title with Replace.
+// Replace may refer to capture groups with $1 or ${name}.
+type TitleRewrite struct {
+	Regex   regexpYaml `yaml:"regex"`
+	Replace string     `yaml:"replace" expand:"-"`
+}
+
 type cssSelectorYaml struct {
 	cascadia.Sel
+	// raw is the selector as written in the config.
+	raw string
 }
 
 func (c *cssSelectorYaml) UnmarshalYAML(value *yaml.Node) error {
 	if value.Kind != yaml.ScalarNode {
 		return fmt.Errorf("expected scalar node for CSS selector, got %v", value.Kind)
 	}
+	// Selectors using ${ENV} or templates are parsed once expandValues has
+	// expanded them.
+	if strings.Contains(value.Value, "${") || strings.Contains(value.Value, "{{") {
+		c.raw = value.Value
+		return nil
+	}
 	selector, err := css.Parse(value.Value)
 	if err != nil {
 		return fmt.Errorf("invalid CSS selector '%s': %w", value.Value, err)
 	}
 	c.Sel = selector
+	c.raw = value.Value
 	return nil
 }
 
@@ -147,15 +209,32 @@ func (c *cssSelectorYaml) MarshalYAML() (interface{}, error) {
 
 // Transform is a description of what should be done with a selector.
 type Transform struct {
-	CssSelector                cssSelectorYaml  `yaml:"css"`                                      // The CSS selector to match elements
-	Type                       string           `yaml:"type"`                                     // The type of the element
-	Attribute                  string           `yaml:"attr,omitempty"`                           // Use the value of this attribute as basis
-	RequireText                *regexpYaml      `yaml:"requiretext,omitempty"`                    // Require text matches the given regexp
-	SkipText                   *regexpYaml      `yaml:"skiptext,omitempty"`                       // Skip this entry if the text matches this regexp
-	MatchPath                  *regexpYaml      `yaml:"matchpath,omitempty"`                      // Skip files that don't match this path
-	TOCRoot                    bool             `yaml:"toc_root,omitempty"`                       // If true, this entry is a root in the TOC
-	TOCChild                   bool             `yaml:"toc_child,omitempty"`                      // If true, this entry is a child in the TOC
-	CssSelectorForSearchPrefix *cssSelectorYaml `yaml:"css_selector_for_search_prefix,omitempty"` // If true, use the page title as a prefix in search results
+	CssSelector                cssSelectorYaml        `yaml:"css"`                                      // The CSS selector to match elements
+	Type                       string                 `yaml:"type"`                                     // The type of the element
+	Attribute                  string                 `yaml:"attr,omitempty"`                           // Use the value of this attribute as basis
+	RequireText                *regexpYaml            `yaml:"requiretext,omitempty"`                    // Require text matches the given regexp
+	SkipText                   *regexpYaml            `yaml:"skiptext,omitempty"`                       // Skip this entry if the text matches this regexp
+	MatchPath                  *regexpYaml            `yaml:"matchpath,omitempty"`                      // Skip files that don't match this path
+	MatchPageCss               *cssSelectorYaml       `yaml:"match_page_css,omitempty"`                 // Skip pages that have no element matching this selector
+	MatchMeta                  map[string]*regexpYaml `yaml:"match_meta,omitempty"`                     // Skip pages whose <meta> content for each name doesn't match
+	TOCRoot                    bool                   `yaml:"toc_root,omitempty"`                       // If true, this entry is a root in the TOC
+	TOCChild                   bool                   `yaml:"toc_child,omitempty"`                      // If true, this entry is a child in the TOC
+	TOCLevel                   *int                   `yaml:"toc_level,omitempty"`                      // Nest this entry at this TOC level; 1 is the root, 0 is a leaf
+	TOCLevelFromHeading        bool                   `yaml:"toc_level_from_heading,omitempty"`         // Use the heading depth (h1 = 1, h2 = 2, ...) as the TOC level
+	CssSelectorForSearchPrefix *cssSelectorYaml       `yaml:"css_selector_for_search_prefix,omitempty"` // Use the text of this element as a prefix in search results
+	SearchPrefix               []PrefixSource         `yaml:"search_prefix,omitempty"`                  // Ordered sources joined in front of the name in search results
+	NameRegex                  *regexpYaml            `yaml:"name_regex,omitempty"`                     // Rewrite the name by replacing matches of this regexp
+	NameReplace                string                 `yaml:"name_replace,omitempty" expand:"-"`        // Replacement for name_regex matches; may use $1 or ${name}
+	CollapseWhitespace         bool                   `yaml:"collapse_whitespace,omitempty"`            // Collapse runs of whitespace in the name into one space
+	TrimChars                  string                 `yaml:"trim_chars,omitempty"`                     // Trim these characters from both ends of the name
+	NameCase                   string                 `yaml:"name_case,omitempty"`                      // Convert the name to "lower" or "upper" case
+	ExpandOptional             bool                   `yaml:"expand_optional,omitempty"`                // Add a name with and without each "[...]" segment, e.g. --[no]flag
+	Aliases                    []Alias                `yaml:"aliases,omitempty"`                        // Extra names that point at the same entry
+	DescriptionCss             *cssSelectorYaml       `yaml:"description_css,omitempty"`                // Summarize the entry with the next element matching this selector
+	Priority                   int                    `yaml:"priority,omitempty"`                       // Selectors with a higher priority run first on each element
+	Exclusive                  bool                   `yaml:"exclusive,omitempty"`                      // Don't run any other selector on an element this one matched
+	Group                      string                 `yaml:"group,omitempty"`                          // Backup selectors in the same group run if this group finds nothing on a page
+	Statuses                   []Status               `yaml:"statuses,omitempty"`                       // Tag entries that are deprecated, experimental, etc.
 }
 
 func main() {
@@ -187,52 +266,260 @@ func commands() []*cli.Command {
 					Name:  "version",
 					Usage: "The bazel docset version",
 				},
+				&cli.StringFlag{
+					Name:  "export-flags",
+					Usage: "Also write the command-line flags next to the docset, as a comma-separated list of formats (json, csv).",
+				},
+				&cli.StringFlag{
+					Name:  "profile",
+					Value: profileDash,
+					Usage: "The viewer to build for: dash, or zeal for a docset that works in both Zeal and Dash.",
+				},
+				&cli.BoolFlag{
+					Name:  "fts",
+					Usage: "Turn on Dash full-text search for the docset, and index the text of each page if SQLite has FTS5.",
+				},
+			},
+		},
+		{
+			Name:      "lint-bazelrc",
+			Usage:     "check the flags in .bazelrc files against a doc set",
+			ArgsUsage: "[BAZELRC...]",
+			Action:    lintBazelrc,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "docset",
+					Usage: "The .docset directory of the Bazel version to check against.",
+				},
+				&cli.StringFlag{
+					Name:  "flags",
+					Usage: "A flags JSON file from `build --export-flags` to check against.",
+				},
+				&cli.StringFlag{
+					Name:  "previous",
+					Usage: "A .docset directory or flags JSON file of the version being upgraded from.",
+				},
+				&cli.StringFlag{
+					Name:  "workspace",
+					Usage: "The directory %workspace% refers to. Defaults to the directory of each file.",
+				},
+			},
+		},
+		{
+			Name:   "migration-report",
+			Usage:  "report entries removed, added or deprecated between two doc sets",
+			Action: migrationReport,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "from",
+					Usage: "The .docset directory of the version being upgraded from.",
+				},
+				&cli.StringFlag{
+					Name:  "to",
+					Usage: "The .docset directory of the version being upgraded to.",
+				},
+				&cli.StringFlag{
+					Name:  "types",
+					Value: "Option,Global,Provider,Field,Function,Class,Attribute",
+					Usage: "A comma-separated list of entry types to compare.",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Value: "markdown",
+					Usage: "The report format: markdown or html.",
+				},
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "The file to write the report to. Defaults to stdout.",
+				},
+			},
+		},
+		{
+			Name:      "query",
+			Usage:     "search the index of a built doc set",
+			ArgsUsage: "TERM",
+			Action:    query,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "docset",
+					Aliases: []string{"d"},
+					Usage:   "The path to the .docset directory.",
+				},
+				&cli.IntFlag{
+					Name:  "limit",
+					Value: 20,
+					Usage: "The maximum number of entries to print.",
+				},
+				&cli.BoolFlag{
+					Name:  "text",
+					Usage: "Search the text of the pages instead of entry names. Needs a docset built with --fts.",
+				},
+			},
+		},
+		{
+			Name:   "serve",
+			Usage:  "preview a built doc set in a browser",
+			Action: serve,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "docset",
+					Aliases: []string{"d"},
+					Usage:   "The path to the .docset directory.",
+				},
+				&cli.StringFlag{
+					Name:  "addr",
+					Value: "localhost:8080",
+					Usage: "The address to serve on.",
+				},
 			},
 		},
 	}
 }
 
-func build(c *cli.Context) error {
+// loadConfig reads and validates the configuration file at cf for building
+// the given docset version.
+func loadConfig(cf, version string) (Dashing, error) {
 	var dashing Dashing
 
+	config, err := readConfig(cf, version)
+	if err != nil {
+		return dashing, err
+	}
+
+	conf, err := yaml.Marshal(config)
+	if err != nil {
+		return dashing, fmt.Errorf("%s: %w", cf, err)
+	}
+	if err := yaml.Unmarshal(conf, &dashing); err != nil {
+		return dashing, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	dashing.docsetVersion = version
+	dashing.configDir = filepath.Dir(cf)
+
+	if err := dashing.expandValues(); err != nil {
+		return dashing, fmt.Errorf("%s: %w", cf, err)
+	}
+
+	if err := dashing.resolveTypes(); err != nil {
+		return dashing, fmt.Errorf("%s: %w", cf, err)
+	}
+
+	if err := dashing.Markdown.loadTemplate(); err != nil {
+		return dashing, fmt.Errorf("%s: %w", cf, err)
+	}
+
+	if err := dashing.Stardoc.resolveTypes(&dashing); err != nil {
+		return dashing, fmt.Errorf("%s: %w", cf, err)
+	}
+
+	for i := range dashing.Sources {
+		s := &dashing.Sources[i]
+		if s.Markdown != nil {
+			if err := s.Markdown.loadTemplate(); err != nil {
+				return dashing, fmt.Errorf("%s: sources[%d]: %w", cf, i, err)
+			}
+		}
+		if s.Stardoc != nil {
+			if err := s.Stardoc.resolveTypes(&dashing); err != nil {
+				return dashing, fmt.Errorf("%s: sources[%d]: %w", cf, i, err)
+			}
+		}
+	}
+
+	if err := dashing.BuildLanguage.resolveTypes(&dashing); err != nil {
+		return dashing, fmt.Errorf("%s: %w", cf, err)
+	}
+	return dashing, nil
+}
+
+func build(c *cli.Context) error {
 	cf := strings.TrimSpace(c.String("config"))
 	if len(cf) == 0 {
 		cf = "./dashing.yaml"
 	}
 
-	conf, err := os.ReadFile(cf)
+	dashing, err := loadConfig(cf, c.String("version"))
 	if err != nil {
-		fmt.Printf("Failed to open configuration file '%s': %s (Run `dashing init`?)\n", cf, err)
+		fmt.Printf("%s\n", err)
 		os.Exit(1)
 	}
 
-	if err := yaml.Unmarshal(conf, &dashing); err != nil {
-		fmt.Printf("Failed to parse YAML: %s", err)
+	name := dashing.Package
+	if c.Bool("fts") {
+		dashing.DefaultFTSEnabled = true
+	}
+	if err := dashing.applyProfile(c.String("profile")); err != nil {
+		fmt.Printf("%s\n", err)
 		os.Exit(1)
 	}
 
-	dashing.docsetVersion = c.String("version")
-
-	name := dashing.Package
-
-	fmt.Printf("Building %s from files in '%s'.\n", name, dashing.WalkRoot)
-
 	writer := newFileWriter(c.String("output"))
 
 	addPlist(name, &dashing, writer)
-	if len(dashing.Icon32x32) > 0 {
-		err := writer.copyFile(path.Join(dashing.Icon32x32), "icon.png")
-		if err != nil {
-			fmt.Printf("Error copying icon: %s\n", err)
-		}
-	}
+	writeIcons(dashing, writer)
 	db, err := writer.initDB(name)
 	if err != nil {
 		fmt.Printf("Failed to create database: %s\n", err)
 		return nil
 	}
 	defer db.Close()
-	texasRanger(dashing.WalkRoot, writer, dashing, db)
+	if dashing.DefaultFTSEnabled {
+		// Dash's own full-text search works without the table, so a missing
+		// FTS5 module only leaves out the page text index.
+		if err := initPageText(db); err != nil {
+			fmt.Printf("WARNING: Not indexing page text: %s\n", err)
+		} else {
+			dashing.pageText = true
+		}
+	}
+	if dashing.BuildLanguage.enabled() {
+		if err := dashing.BuildLanguage.load(); err != nil {
+			fmt.Printf("Failed to read build language: %s\n", err)
+			return nil
+		}
+	}
+	merged := []string{}
+	for _, source := range dashing.sources() {
+		if source.source != nil {
+			fmt.Printf("Building %s source '%s' from files in '%s'.\n", name, source.source.Name, source.WalkRoot)
+		} else {
+			fmt.Printf("Building %s from files in '%s'.\n", name, source.WalkRoot)
+		}
+		if source.DuplicatePages.enabled() {
+			source.duplicates, err = findDuplicates(source.WalkRoot, source)
+			if err != nil {
+				fmt.Printf("Failed to find duplicate pages: %s\n", err)
+				return nil
+			}
+		}
+		merged = append(merged, texasRanger(source.WalkRoot, writer, source, db)...)
+	}
+	if dashing.Dedupe.enabled() {
+		if err := dashing.Dedupe.writeReport(merged); err != nil {
+			fmt.Printf("Error writing dedupe report: %s\n", err)
+		}
+	}
+
+	if dashing.profile == profileZeal {
+		if err := writeZealMeta(dashing, writer); err != nil {
+			fmt.Printf("Failed to write meta.json: %s\n", err)
+		}
+		problems, err := checkIndexPaths(db, filepath.Join(writer.destRoot, "Contents/Resources/Documents"))
+		if err != nil {
+			fmt.Printf("Failed to check index paths: %s\n", err)
+		}
+		for _, p := range problems {
+			fmt.Printf("Broken index path: %s\n", p)
+		}
+		fmt.Printf("Checked index paths for Zeal: %d problems\n", len(problems))
+	}
+
+	if formats := strings.TrimSpace(c.String("export-flags")); len(formats) > 0 {
+		if err := exportFlags(c.String("output"), dashing, strings.Split(formats, ",")); err != nil {
+			fmt.Printf("Failed to export flags: %s\n", err)
+		}
+	}
 
 	// for _, dir := range dashing.CopyDirsIntoDocs {
 	// 	fileSystem := os.DirFS(dir)
@@ -248,85 +535,133 @@ func build(c *cli.Context) error {
 	return nil
 }
 
-func addPlist(name string, config *Dashing, writer fileWriter) {
-	var file bytes.Buffer
-	t := template.Must(template.New("plist").Parse(plist))
+// capitalize upper-cases the first letter of s, e.g. "Bazel" for "bazel".
+func capitalize(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
 
+func addPlist(name string, config *Dashing, writer fileWriter) {
 	fancyName := config.Name
 	if len(fancyName) == 0 {
-		fancyName = strings.ToTitle(name)
+		fancyName = capitalize(name)
 	}
 
-	aj := "false"
-	if config.AllowJS {
-		aj = "true"
+	info := map[string]interface{}{
+		"CFBundleIdentifier":   name,
+		"CFBundleName":         fancyName,
+		"DocSetPlatformFamily": name,
+		"isDashDocset":         true,
+		"DashDocSetFamily":     "dashtoc3",
+		"dashIndexFilePath":    config.Index,
+		"isJavaScriptEnabled":  config.AllowJS,
 	}
-
-	tvars := map[string]string{
-		"Name":        name,
-		"FancyName":   fancyName,
-		"Index":       config.Index,
-		"AllowJS":     aj,
-		"ExternalURL": config.ExternalURL,
+	optional := map[string]string{
+		"DashDocSetFallbackURL":   config.ExternalURL,
+		"DashDocSetKeyword":       config.Keyword,
+		"DashDocSetPluginKeyword": config.PluginKeyword,
+		"DashWebSearchKeyword":    config.WebSearchKeyword,
+		"DashDocSetPlayURL":       config.PlayURL,
+	}
+	for k, v := range optional {
+		if len(v) > 0 {
+			info[k] = v
+		}
+	}
+	if config.DefaultFTSEnabled {
+		info["DashDocSetDefaultFTSEnabled"] = true
+	}
+	for k, v := range config.InfoPlist {
+		info[k] = v
 	}
 
-	err := t.Execute(&file, tvars)
+	file, err := plist.MarshalIndent(info, plist.XMLFormat, "\t")
 	if err != nil {
 		fmt.Printf("Failed: %s\n", err)
 		return
 	}
-	writer.WriteFile("Contents/Info.plist", file.Bytes(), 0755)
+	writer.WriteFile("Contents/Info.plist", file, 0755)
 }
 
-// texasRanger is... wait for it... a WALKER!
-func texasRanger(base string, writer fileWriter, dashing Dashing, db *sql.DB) error {
+// texasRanger is... wait for it... a WALKER! It returns the entries merged by
+// dedupe.
+func texasRanger(base string, writer fileWriter, dashing Dashing, db *sql.DB) []string {
+	merged := []string{}
 	filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
 		if info.IsDir() || dashing.shouldIgnoreFile(path) {
 			return nil
 		}
+		var result parseResult
 		if htmlish(path) {
-			fmt.Printf("%s looks like HTML\n", path)
-			result, err := parseHTML(path, dashing)
-			if err != nil {
-				fmt.Printf("Error parsing %s: %s\n", path, err)
+			if canonical, ok := dashing.duplicates[filepath.Clean(path)]; ok {
+				fmt.Printf("Skipping %s (duplicate of %s)\n", path, canonical)
 				return nil
 			}
-			for _, ref := range result.refs {
-				// the real path needs to be:
-				// <dash_entry_name=NAME><dash_entry_originalName=BUNDLE_NAME.NAME><dash_entry_menuDescription=BUNDLE_NAME>HTML_FILE#//dash_ref_NAME/TYPE/NAME/LEVEL>
-				path := fmt.Sprintf("<dash_entry_name=%s><dash_entry_originalName=%s><dash_entry_menuDescription=%s>%s", ref.name, ref.name, ref.menuDescription, ref.href)
-				fmt.Printf("Match(%s): '%s' is type %s at %s\n", ref.selector, ref.name, ref.etype, ref.href)
-				fmt.Println(path)
-				if ref.name == "" {
-					fmt.Printf("ERROR: No name found %+v\n", ref)
-					continue
-				}
-				db.Exec(`INSERT OR IGNORE INTO searchIndex(name, type, path) VALUES (?,?,?)`, ref.name, ref.etype, path)
-			}
-			writer.addHtml(path, result.htmlNode)
-			// for _, file := range result.usedFiles {
-			// 	if dashing.shouldIgnoreFile(file) {
-			// 		continue
-			// 	}
-			// 	err = copyFile(file, filepath.Join(destination, file))
-			// 	if err != nil {
-			// 		fmt.Printf("Error copying %s: %s\n", file, err)
-			// 	}
-			// }
-			return nil
+			fmt.Printf("%s looks like HTML\n", path)
+			result, err = parseHTML(path, dashing)
+		} else if dashing.Stardoc.matches(path) {
+			fmt.Printf("%s looks like Stardoc output\n", path)
+			result, err = parseStardoc(path, dashing)
+		} else if dashing.Markdown.Enabled && markdownish(path) {
+			fmt.Printf("%s looks like Markdown\n", path)
+			result, err = parseMarkdown(path, dashing)
 		} else {
-			if dashing.shouldIgnoreFile(path) {
-				return nil
-			}
-			err = writer.addContentFile(path)
+			err = writer.addContentFile(path, dashing.documentPath(path))
 			// err = copyFile(path, filepath.Join(destination, path))
 			if err != nil {
 				fmt.Printf("Error copying %s: %s\n", path, err)
 			}
+			return nil
+		}
+		if err != nil {
+			fmt.Printf("Error parsing %s: %s\n", path, err)
+			return nil
+		}
+		if dashing.Dedupe.enabled() {
+			var pageMerged []string
+			result.refs, pageMerged = dashing.Dedupe.dedupe(result.refs)
+			merged = append(merged, pageMerged...)
+		}
+		docPath := dashing.documentPath(result.path)
+		for _, ref := range result.refs {
+			ref.name = dashing.searchName(ref)
+			if strings.HasPrefix(ref.href, result.path) {
+				ref.href = docPath + strings.TrimPrefix(ref.href, result.path)
+			}
+			// the real path needs to be:
+			// <dash_entry_name=NAME><dash_entry_originalName=BUNDLE_NAME.NAME><dash_entry_menuDescription=BUNDLE_NAME>HTML_FILE#//dash_ref_NAME/TYPE/NAME/LEVEL>
+			path := fmt.Sprintf("<dash_entry_name=%s><dash_entry_originalName=%s><dash_entry_menuDescription=%s>%s", ref.name, ref.name, ref.menuDescription(), ref.href)
+			fmt.Printf("Match(%s): '%s' is type %s at %s\n", ref.selector, ref.name, ref.etype, ref.href)
+			fmt.Println(path)
+			if ref.name == "" {
+				fmt.Printf("ERROR: No name found %+v\n", ref)
+				continue
+			}
+			if err := insertRef(db, ref, path); err != nil {
+				fmt.Printf("ERROR: Failed to index '%s': %s\n", ref.name, err)
+			}
 		}
+		writer.addHtml(docPath, result.htmlNode)
+		if dashing.pageText {
+			if err := insertPageText(db, docPath, result.htmlNode); err != nil {
+				fmt.Printf("ERROR: Failed to index the text of %s: %s\n", docPath, err)
+			}
+		}
+		// for _, file := range result.usedFiles {
+		// 	if dashing.shouldIgnoreFile(file) {
+		// 		continue
+		// 	}
+		// 	err = copyFile(file, filepath.Join(destination, file))
+		// 	if err != nil {
+		// 		fmt.Printf("Error copying %s: %s\n", file, err)
+		// 	}
+		// }
 		return nil
 	})
-	return nil
+	return merged
 }
 
 func newFileWriter(destRoot string) fileWriter {
@@ -358,11 +693,36 @@ func (w fileWriter) initDB(name string) (*sql.DB, error) {
 		return db, err
 	}
 
+	// Dash ignores this table. It keeps entry summaries for other tools.
+	if _, err := db.Exec(`CREATE TABLE entryDescription(id INTEGER PRIMARY KEY REFERENCES searchIndex(id), description TEXT)`); err != nil {
+		return db, err
+	}
+
 	return db, nil
 }
 
-func (w fileWriter) addContentFile(src string) error {
-	return copyFile(src, filepath.Join(w.destRoot, "Contents/Resources/Documents", src))
+// insertRef adds ref to the search index under the given Dash path.
+func insertRef(db *sql.DB, ref *reference, path string) error {
+	res, err := db.Exec(`INSERT OR IGNORE INTO searchIndex(name, type, path) VALUES (?,?,?)`, ref.name, ref.etype, path)
+	if err != nil {
+		return err
+	}
+	if len(ref.description) == 0 {
+		return nil
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO entryDescription(id, description) VALUES (?,?)`, id, ref.description)
+	return err
+}
+
+func (w fileWriter) addContentFile(src, dest string) error {
+	return copyFile(src, filepath.Join(w.destRoot, "Contents/Resources/Documents", dest))
 }
 
 func (w fileWriter) copyFile(src string, dest string) error {
@@ -414,10 +774,17 @@ func htmlish(filename string) bool {
 }
 
 type reference struct {
-	selector, name, etype, href, menuDescription string
+	selector, name, etype, href, pageTitle, description, group string
+	// source identifies what produced the entry. Dedupe keeps entries from
+	// one source together.
+	source string
+	// labels are the statuses the entry was tagged with, e.g. "Deprecated".
+	labels []string
 }
 
 type parseResult struct {
+	// path is where the page is written inside the docset's Documents.
+	path      string
 	refs      []*reference
 	usedFiles []string
 	htmlNode  *html.Node
@@ -433,7 +800,15 @@ func parseHTML(filepath string, dashing Dashing) (parseResult, error) {
 	if err != nil {
 		return parseResult{}, err
 	}
+	return parseHTMLNode(top, filepath, dashing, dashing.pageRefs)
+}
+
+// refFinder finds the entries on a page after it has been cleaned up.
+type refFinder func(top *html.Node, filepath string, headNode *html.Node) []*reference
 
+// parseHTMLNode cleans up an already parsed page for the docset and finds its
+// entries with find. filepath is the page's path inside the docset.
+func parseHTMLNode(top *html.Node, filepath string, dashing Dashing, find refFinder) (parseResult, error) {
 	// head
 	headMatcher := css.MustCompile("head")
 	headNode := headMatcher.MatchFirst(top)
@@ -442,7 +817,7 @@ func parseHTML(filepath string, dashing Dashing) (parseResult, error) {
 	roots := root.MatchAll(top)
 	usedFiles := make([]string, 0)
 	for _, node := range roots {
-		for _, attribute := range node.Attr {
+		for i, attribute := range node.Attr {
 			if attribute.Key == "href" || attribute.Key == "src" {
 				url, err := url.Parse(attribute.Val)
 				if err != nil {
@@ -451,6 +826,10 @@ func parseHTML(filepath string, dashing Dashing) (parseResult, error) {
 				}
 				if url.Scheme == "" && url.Host == "" && url.Path != "" {
 					// relative path
+					if canonical, ok := dashing.canonicalLink(filepath, url.Path); ok {
+						url.Path = canonical
+						node.Attr[i].Val = url.String()
+					}
 					toCopy := path.Join(path.Dir(filepath), url.Path)
 					usedFiles = append(usedFiles, toCopy)
 				}
@@ -465,6 +844,10 @@ func parseHTML(filepath string, dashing Dashing) (parseResult, error) {
 		}
 	}
 
+	if dashing.profile == profileZeal {
+		stripScripts(top)
+	}
+
 	if dashing.CssSelectorForBody != nil {
 		// head
 		bodyMatcher := css.MustCompile("body")
@@ -490,56 +873,135 @@ func parseHTML(filepath string, dashing Dashing) (parseResult, error) {
 		}
 	}
 
-	titleElem := css.MustCompile("title").MatchFirst(top)
-	if titleElem != nil {
-		titleText := text(titleElem)
-		titleText = strings.Replace(titleText, "  |  Bazel", "", 1)
-		// Dash only wants you to include the version in the title if it is for a specific version
-		if dashing.docsetVersion != "latest" {
-			titleText = fmt.Sprintf("%s | %s", titleText, dashing.docsetVersion)
+	if titleElem := css.MustCompile("title").MatchFirst(top); titleElem != nil {
+		titleText, err := dashing.rewriteTitle(text(titleElem))
+		if err != nil {
+			fmt.Printf("ERROR: %s: Error rewriting title: %s\n", filepath, err)
+		} else {
+			setText(titleElem, titleText)
 		}
-		// Update the text inside of the <title> element
-		titleElem.FirstChild.Data = titleText
 	}
 
-	refs := findRefs(top, dashing.Selectors, dashing, filepath, headNode)
-	if len(refs) == 0 {
-		refs = findRefs(top, dashing.BackupSelectors, dashing, filepath, headNode)
+	if dashing.profile == profileZeal {
+		if elements := scriptRendered(top); len(elements) > 0 {
+			fmt.Printf("WARNING: %s: without its scripts, Zeal won't render <%s>\n", filepath, strings.Join(elements, ">, <"))
+		}
 	}
+
 	return parseResult{
-		refs:      refs,
+		path:      filepath,
+		refs:      find(top, filepath, headNode),
 		usedFiles: usedFiles,
 		htmlNode:  top,
 	}, nil
 }
 
-func findRefs(top *html.Node, selectors []Transform, dashing Dashing, filepath string, headNode *html.Node) []*reference {
-	refs := []*reference{}
-	// tocHeaderName := ""
+// selectorRefs finds the entries on a page with the configured selectors,
+// skipping claimed elements.
+func (d Dashing) selectorRefs(top *html.Node, filepath string, headNode *html.Node, claimed map[*html.Node]bool) []*reference {
+	matched := map[*html.Node]bool{}
+	refs := findRefs(top, d.Selectors, d, filepath, headNode, claimed, matched)
+	// Backup selectors skip elements the main selectors already indexed.
+	for n := range matched {
+		claimed[n] = true
+	}
+	return append(refs, findRefs(top, d.backupSelectorsFor(top, filepath, refs), d, filepath, headNode, claimed, matched)...)
+}
+
+// pageRefs finds the entries on a page with the build language, if there is
+// one, and the configured selectors. Build language entries come first so that
+// they win when dedupe merges them with scraped ones, and selectors skip the
+// elements they anchor, so a rule heading doesn't get a second entry and TOC
+// anchor.
+func (d Dashing) pageRefs(top *html.Node, filepath string, headNode *html.Node) []*reference {
+	claimed := map[*html.Node]bool{}
+	refs := d.BuildLanguage.refs(d, top, filepath, headNode, claimed)
+	return append(refs, d.selectorRefs(top, filepath, headNode, claimed)...)
+}
+
+// pageTitle returns the text of the element matching css_selector_for_title.
+func (d *Dashing) pageTitle(top *html.Node) string {
+	if d.CssSelectorForTitle == nil {
+		return ""
+	}
+	title := css.Selector(d.CssSelectorForTitle.Sel.Match).MatchFirst(top)
+	if title == nil {
+		return ""
+	}
+	return text(title)
+}
+
+// rewriteTitle applies the configured title rewrites and template to a page title.
+func (d *Dashing) rewriteTitle(title string) (string, error) {
+	for _, rw := range d.TitleRewrites {
+		title = rw.Regex.ReplaceAllString(title, rw.Replace)
+	}
+	if d.TitleTemplate == nil {
+		return title, nil
+	}
+
+	var b bytes.Buffer
+	err := d.TitleTemplate.Execute(&b, map[string]string{
+		"Title":   title,
+		"Version": d.docsetVersion,
+		"Name":    d.Name,
+		"Package": d.Package,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
 
-	titleString := ""
-	if dashing.CssSelectorForTitle != nil {
-		title := css.Selector(dashing.CssSelectorForTitle.Sel.Match).MatchFirst(top)
-		if title != nil {
-			titleString = text(title)
+// backupSelectorsFor returns the backup selectors to run on a page, given the
+// refs the main selectors found. Ungrouped backup selectors run only if
+// nothing was found; grouped ones run if their group was eligible for the page
+// but found nothing.
+func (d *Dashing) backupSelectorsFor(top *html.Node, filepath string, refs []*reference) []Transform {
+	found := map[string]bool{}
+	for _, ref := range refs {
+		found[ref.group] = true
+	}
+	failed := map[string]bool{}
+	for _, sel := range d.Selectors {
+		if len(sel.Group) > 0 && !found[sel.Group] && sel.matchesPage(top, filepath) {
+			failed[sel.Group] = true
+		}
+	}
 
+	backups := []Transform{}
+	for _, sel := range d.BackupSelectors {
+		if (len(sel.Group) == 0 && len(refs) == 0) || failed[sel.Group] {
+			backups = append(backups, sel)
 		}
 	}
+	return backups
+}
+
+// findRefs runs selectors over the page and returns the entries they find.
+// Elements claimed by an exclusive selector are added to claimed and skipped
+// by later selectors. Every element a selector indexes is added to matched.
+func findRefs(top *html.Node, selectors []Transform, dashing Dashing, filepath string, headNode *html.Node, claimed, matched map[*html.Node]bool) []*reference {
+	refs := []*reference{}
+	// tocHeaderName := ""
+
+	titleString := dashing.pageTitle(top)
 
 	matchingSelectors := make([]*Transform, 0)
 	for _, sel := range selectors {
-		// Skip this selector if file path doesn't match
-		if sel.MatchPath == nil {
+		if sel.matchesPage(top, filepath) {
 			matchingSelectors = append(matchingSelectors, &sel)
-		} else {
-			if sel.MatchPath.MatchString(filepath) {
-				matchingSelectors = append(matchingSelectors, &sel)
-			}
 		}
 	}
+	sort.SliceStable(matchingSelectors, func(i, j int) bool {
+		return matchingSelectors[i].Priority > matchingSelectors[j].Priority
+	})
 
 	for n := range top.Descendants() {
 		for _, sel := range matchingSelectors {
+			if claimed[n] {
+				break
+			}
 			if !sel.CssSelector.Match(n) {
 				continue
 			}
@@ -561,48 +1023,98 @@ func findRefs(top *html.Node, selectors []Transform, dashing Dashing, filepath s
 			} else {
 				name = textString
 			}
+			names := sel.names(name)
+			name = names[0]
 
-			prefix := ""
-			if sel.CssSelectorForSearchPrefix != nil {
-				node := css.Query(top, sel.CssSelectorForSearchPrefix)
-				if node != nil {
-					nodeText := text(node)
-					// Only set prefix if it's different from the current name
-					// This saves us from having a prefix like "prefix.prefix.name"
-					if nodeText != textString {
-						prefix = fmt.Sprintf("%s.", nodeText)
-					}
+			linkHref := attr(n, "href")
+			if !strings.HasPrefix(linkHref, "#") || len(linkHref) == 0 {
+				linkHref = "#" + attr(n, "id")
+			}
+			// if len(linkHref) == 0 and {
+			// linkHref = fmt.Sprintf(":~:text=%s", url.QueryEscape(name))
+			// linkHref = anchor(n)
+			// }
+			descriptionNode := sel.descriptionNode(n)
+			description := sel.description(n)
+			entryNames := []string{}
+			for _, name := range names {
+				entryNames = append(entryNames, sel.searchPrefix(top, filepath, name)+name)
+			}
+			for _, name := range entryNames[:len(names)] {
+				entryNames = append(entryNames, sel.aliases(n, name)...)
+			}
+			seen := map[string]bool{}
+			indexed := false
+			for _, entryName := range entryNames {
+				if seen[entryName] {
+					continue
+				}
+				seen[entryName] = true
+				ref := &reference{
+					selector:    sel.CssSelector.String(),
+					source:      sel.Type + " " + sel.CssSelector.String(),
+					name:        entryName,
+					etype:       sel.Type,
+					href:        filepath + linkHref,
+					pageTitle:   titleString,
+					description: description,
+					group:       sel.Group,
+				}
+				if sel.applyStatuses(ref, n, descriptionNode, textString) {
+					refs = append(refs, ref)
+					indexed = true
+				} else {
+					fmt.Printf("Skipping entry for '%s' (Excluded by status)\n", entryName)
 				}
 			}
 
-			if !strings.HasSuffix(filepath, "-2.html") {
-				linkHref := attr(n, "href")
-				if !strings.HasPrefix(linkHref, "#") || len(linkHref) == 0 {
-					linkHref = "#" + attr(n, "id")
-				}
-				// if len(linkHref) == 0 and {
-				// linkHref = fmt.Sprintf(":~:text=%s", url.QueryEscape(name))
-				// linkHref = anchor(n)
-				// }
-				refs = append(refs,
-					&reference{
-						selector:        sel.CssSelector.String(),
-						name:            prefix + name,
-						etype:           sel.Type,
-						href:            filepath + linkHref,
-						menuDescription: titleString,
-					},
-				)
+			// Elements whose every name was excluded stay out of the TOC too.
+			if indexed {
+				tocAnchor, linkNode := tocAnchorAndLinkNode(name, sel.Type, sel.tocLevel(n))
+				headNode.AppendChild(linkNode)
+				n.Parent.InsertBefore(tocAnchor, n)
 			}
 
-			tocAnchor, linkNode := tocAnchorAndLinkNode(name, sel.Type, sel.TOCRoot)
-			headNode.AppendChild(linkNode)
-			n.Parent.InsertBefore(tocAnchor, n)
+			matched[n] = true
+			if sel.Exclusive {
+				claimed[n] = true
+			}
 		}
 	}
 	return refs
 }
 
+// matchesPage reports whether the selector should run on the page at filepath.
+func (t *Transform) matchesPage(top *html.Node, filepath string) bool {
+	// Skip this selector if file path doesn't match
+	if t.MatchPath != nil && !t.MatchPath.MatchString(filepath) {
+		return false
+	}
+	if t.MatchPageCss != nil && css.Query(top, t.MatchPageCss) == nil {
+		return false
+	}
+	for name, re := range t.MatchMeta {
+		content, ok := meta(top, name)
+		if !ok || !re.MatchString(content) {
+			return false
+		}
+	}
+	return true
+}
+
+// meta returns the content of the first <meta> element whose name, property
+// or http-equiv attribute is name.
+func meta(top *html.Node, name string) (string, bool) {
+	for _, n := range css.MustCompile("meta[content]").MatchAll(top) {
+		for _, key := range []string{"name", "property", "http-equiv"} {
+			if strings.EqualFold(attr(n, key), name) {
+				return attr(n, "content"), true
+			}
+		}
+	}
+	return "", false
+}
+
 func text(node *html.Node) string {
 	var b bytes.Buffer
 	for c := node.FirstChild; c != nil; c = c.NextSibling {
@@ -615,6 +1127,14 @@ func text(node *html.Node) string {
 	return strings.TrimSpace(b.String())
 }
 
+// setText replaces all children of node with a single text node.
+func setText(node *html.Node, data string) {
+	for node.FirstChild != nil {
+		node.RemoveChild(node.FirstChild)
+	}
+	node.AppendChild(&html.Node{Type: html.TextNode, Data: data})
+}
+
 func attr(node *html.Node, key string) string {
 	for _, a := range node.Attr {
 		if a.Key == key {
@@ -658,13 +1178,38 @@ func autolink(target string) *html.Node {
 
 var counter = 0
 
-func tocAnchorAndLinkNode(name, etype string, isSectionHeader bool) (*html.Node, *html.Node) {
+// tocLevel returns the level of n in the page's table of contents. Level 1 is
+// the root, higher levels nest under the closest preceding lower level, and 0
+// is a leaf under the closest preceding section.
+func (t *Transform) tocLevel(n *html.Node) int {
+	switch {
+	case t.TOCLevel != nil:
+		return *t.TOCLevel
+	case t.TOCLevelFromHeading:
+		switch n.DataAtom {
+		case atom.H1:
+			return 1
+		case atom.H2:
+			return 2
+		case atom.H3:
+			return 3
+		case atom.H4:
+			return 4
+		case atom.H5:
+			return 5
+		case atom.H6:
+			return 6
+		}
+		return 0
+	case t.TOCRoot && !t.TOCChild:
+		return 1
+	}
+	return 0
+}
+
+func tocAnchorAndLinkNode(name, etype string, tocLevel int) (*html.Node, *html.Node) {
 	name = strings.Replace(url.QueryEscape(name), "+", "%20", -1)
 
-	tocLevel := 0 // default level for children
-	if isSectionHeader {
-		tocLevel = 1 // root level
-	}
 	target := fmt.Sprintf("//dash_ref_%d/%s/%s/%d", counter, etype, name, tocLevel)
 	counter++
 
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	css "github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+func TestTOCLevel(t *testing.T) {
+	top := parseTestHTML(t, `<h1>a</h1><h3>b</h3><p>c</p>`)
+	two := 2
+	for _, test := range []struct {
+		name string
+		t    Transform
+		node string
+		want int
+	}{
+		{"leaf", Transform{}, "h1", 0},
+		{"root", Transform{TOCRoot: true}, "h1", 1},
+		{"root and child", Transform{TOCRoot: true, TOCChild: true}, "h1", 0},
+		{"explicit", Transform{TOCLevel: &two, TOCRoot: true}, "h1", 2},
+		{"from heading", Transform{TOCLevelFromHeading: true}, "h3", 3},
+		{"from heading, not a heading", Transform{TOCLevelFromHeading: true}, "p", 0},
+	} {
+		n := css.MustCompile(test.node).MatchFirst(top)
+		if got := test.t.tocLevel(n); got != test.want {
+			t.Errorf("%s: tocLevel(<%s>) = %d, want %d", test.name, test.node, got, test.want)
+		}
+	}
+}
+
+func TestTOCAnchorAndLinkNode(t *testing.T) {
+	anchor, link := tocAnchorAndLinkNode("cc_common.compile step", "Method", 3)
+	name := attr(anchor, "name")
+	if !strings.HasPrefix(name, "//dash_ref_") || !strings.HasSuffix(name, "/Method/cc_common.compile%20step/3") {
+		t.Errorf("anchor name = %q, want //dash_ref_N/Method/cc_common.compile%%20step/3", name)
+	}
+	if attr(anchor, "class") != "dashAnchor" {
+		t.Errorf("anchor class = %q, want dashAnchor", attr(anchor, "class"))
+	}
+	if attr(link, "href") != name {
+		t.Errorf("link href = %q, want %q", attr(link, "href"), name)
+	}
+	if other, _ := tocAnchorAndLinkNode("cc_common.compile step", "Method", 3); attr(other, "name") == name {
+		t.Errorf("two anchors share the name %q", name)
+	}
+}
+
+func TestMatchesPage(t *testing.T) {
+	top := parseTestHTML(t, `<html><head><meta name="Generator" content="Stardoc 0.7"><meta property="og:type" content="article"></head><body><div class="devsite-article"></div></body></html>`)
+	for _, test := range []struct {
+		name string
+		t    Transform
+		path string
+		want bool
+	}{
+		{"no conditions", Transform{}, "a.html", true},
+		{"path", Transform{MatchPath: testRegexp(`^rules/`)}, "rules/a.html", true},
+		{"path mismatch", Transform{MatchPath: testRegexp(`^rules/`)}, "a.html", false},
+		{"page css", Transform{MatchPageCss: testSelector(".devsite-article")}, "a.html", true},
+		{"page css mismatch", Transform{MatchPageCss: testSelector(".devsite-landing")}, "a.html", false},
+		{"meta name, any case", Transform{MatchMeta: map[string]*regexpYaml{"generator": testRegexp(`^Stardoc`)}}, "a.html", true},
+		{"meta property", Transform{MatchMeta: map[string]*regexpYaml{"og:type": testRegexp(`article`)}}, "a.html", true},
+		{"meta mismatch", Transform{MatchMeta: map[string]*regexpYaml{"generator": testRegexp(`^Sphinx`)}}, "a.html", false},
+		{"meta missing", Transform{MatchMeta: map[string]*regexpYaml{"robots": testRegexp(`.*`)}}, "a.html", false},
+		{"all must hold", Transform{MatchPath: testRegexp(`^rules/`), MatchPageCss: testSelector(".devsite-article")}, "a.html", false},
+	} {
+		if got := test.t.matchesPage(top, test.path); got != test.want {
+			t.Errorf("%s: matchesPage(%q) = %v, want %v", test.name, test.path, got, test.want)
+		}
+	}
+}
+
+func TestSelectorRefs(t *testing.T) {
+	const page = `<html><head><title>t</title></head><body>
+<h2 id="api" class="api">compile</h2>
+<h2 id="usage">Usage</h2>
+<h3 id="more">More</h3>
+</body></html>`
+	for _, test := range []struct {
+		name            string
+		selectors       []Transform
+		backupSelectors []Transform
+		want            []string
+	}{
+		{
+			name:            "backups only run if nothing was found",
+			selectors:       []Transform{{CssSelector: *testSelector("h2.api"), Type: "Method"}},
+			backupSelectors: []Transform{{CssSelector: *testSelector("h2"), Type: "Section"}},
+			want:            []string{"Method compile"},
+		},
+		{
+			name: "grouped backups skip elements the main selectors indexed",
+			selectors: []Transform{
+				{CssSelector: *testSelector("h2.api"), Type: "Method", Group: "api"},
+				{CssSelector: *testSelector("dt a"), Type: "Option", Group: "flags"},
+			},
+			backupSelectors: []Transform{
+				{CssSelector: *testSelector("h2"), Type: "Section", Group: "flags"},
+				{CssSelector: *testSelector("h3"), Type: "Guide", Group: "flags"},
+			},
+			want: []string{"Method compile", "Section Usage", "Guide More"},
+		},
+		{
+			name: "exclusive and priority",
+			selectors: []Transform{
+				{CssSelector: *testSelector("h2"), Type: "Section"},
+				{CssSelector: *testSelector("h2.api"), Type: "Method", Priority: 1, Exclusive: true},
+			},
+			want: []string{"Method compile", "Section Usage"},
+		},
+	} {
+		top := parseTestHTML(t, page)
+		head := css.MustCompile("head").MatchFirst(top)
+		d := Dashing{Selectors: test.selectors, BackupSelectors: test.backupSelectors}
+		got := []string{}
+		for _, ref := range d.selectorRefs(top, "a.html", head, map[*html.Node]bool{}) {
+			got = append(got, ref.etype+" "+ref.name)
+		}
+		if strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+			t.Errorf("%s: selectorRefs() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Dedupe configures the merging of index entries found on the same page
+// before they are written to the index.
+type Dedupe struct {
+	// SameName merges entries with the same name and different types.
+	SameName bool `yaml:"same_name"`
+	// SameAnchor merges entries from different selectors that point at the same
+	// anchor. Names from one selector (such as aliases) are kept together.
+	SameAnchor bool `yaml:"same_anchor"`
+	// PreferTypes decides which entry survives a merge: types earlier in the
+	// list win, unlisted types lose, and ties go to the entry found first.
+	PreferTypes []string `yaml:"prefer_types"`
+	// Report is a file to write the list of merged entries to. If empty, the
+	// merges are printed.
+	Report string `yaml:"report"`
+}
+
+func (d Dedupe) enabled() bool {
+	return d.SameName || d.SameAnchor
+}
+
+func (d Dedupe) rank(etype string) int {
+	for i, t := range d.PreferTypes {
+		if t == etype {
+			return i
+		}
+	}
+	return len(d.PreferTypes)
+}
+
+// dedupe removes merged entries from refs, returning the entries to keep and a
+// line describing each merge.
+func (d Dedupe) dedupe(refs []*reference) ([]*reference, []string) {
+	merged := []string{}
+	if d.SameAnchor {
+		refs, merged = d.merge(refs, merged, anchorKey,
+			func(r *reference) string { return r.source })
+	}
+	if d.SameName {
+		refs, merged = d.merge(refs, merged,
+			func(r *reference) string { return r.name },
+			func(r *reference) string { return r.etype })
+	}
+	return refs, merged
+}
+
+// anchorKey groups entries by the anchor they point at. Entries without an
+// anchor, such as elements with no id, aren't grouped: they all point at the
+// top of the page but describe different things.
+func anchorKey(r *reference) string {
+	if _, fragment, _ := strings.Cut(r.href, "#"); len(fragment) == 0 {
+		return ""
+	}
+	return r.href
+}
+
+// merge groups refs by groupKey and, within each group with more than one
+// distinct owner, keeps only the refs of the owner with the preferred type.
+// Refs with an empty key are always kept.
+func (d Dedupe) merge(refs []*reference, merged []string, groupKey, owner func(*reference) string) ([]*reference, []string) {
+	winner := map[string]*reference{}
+	for _, ref := range refs {
+		key := groupKey(ref)
+		if len(key) == 0 {
+			continue
+		}
+		if w, ok := winner[key]; !ok || d.rank(ref.etype) < d.rank(w.etype) {
+			winner[key] = ref
+		}
+	}
+
+	kept := []*reference{}
+	for _, ref := range refs {
+		w, ok := winner[groupKey(ref)]
+		if !ok || owner(ref) == owner(w) {
+			kept = append(kept, ref)
+			continue
+		}
+		merged = append(merged, fmt.Sprintf("%s: merged '%s' (%s) into '%s' (%s)", ref.href, ref.name, ref.etype, w.name, w.etype))
+	}
+	return kept, merged
+}
+
+func (d Dedupe) writeReport(merged []string) error {
+	if len(d.Report) == 0 {
+		for _, line := range merged {
+			fmt.Printf("Dedupe: %s\n", line)
+		}
+		return nil
+	}
+	fmt.Printf("Writing dedupe report (%d merged entries) to %s\n", len(merged), d.Report)
+	return os.WriteFile(d.Report, []byte(strings.Join(merged, "\n")+"\n"), 0644)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	ref := func(name, etype, href, source string) *reference {
+		return &reference{name: name, etype: etype, href: href, source: source}
+	}
+	for _, test := range []struct {
+		name   string
+		dedupe Dedupe
+		refs   []*reference
+		want   []string
+		merged int
+	}{
+		{
+			name:   "same anchor keeps the preferred type and its aliases",
+			dedupe: Dedupe{SameAnchor: true, PreferTypes: []string{"Option", "Section"}},
+			refs: []*reference{
+				ref("Keep going", "Section", "a.html#flag--keep_going", "h3"),
+				ref("--keep_going", "Option", "a.html#flag--keep_going", "dt a"),
+				ref("-k", "Option", "a.html#flag--keep_going", "dt a"),
+			},
+			want:   []string{"--keep_going Option", "-k Option"},
+			merged: 1,
+		},
+		{
+			name:   "entries without an anchor aren't grouped",
+			dedupe: Dedupe{SameAnchor: true},
+			refs: []*reference{
+				ref("Intro", "Guide", "a.html#", "title"),
+				ref("Run", "Section", "a.html#", "h2"),
+				ref("run", "Method", "a.html", "code"),
+			},
+			want: []string{"Intro Guide", "Run Section", "run Method"},
+		},
+		{
+			name:   "ties go to the entry found first",
+			dedupe: Dedupe{SameAnchor: true},
+			refs: []*reference{
+				ref("run", "Method", "a.html#run", "code"),
+				ref("Run", "Section", "a.html#run", "h2"),
+			},
+			want:   []string{"run Method"},
+			merged: 1,
+		},
+		{
+			name:   "same name",
+			dedupe: Dedupe{SameName: true, PreferTypes: []string{"Provider"}},
+			refs: []*reference{
+				ref("CcInfo", "Section", "a.html#x", "h2"),
+				ref("CcInfo", "Provider", "a.html#y", "h1"),
+				ref("CcInfo", "Provider", "a.html#z", "h1"),
+				ref("cc_common", "Section", "a.html#w", "h2"),
+			},
+			want:   []string{"CcInfo Provider", "CcInfo Provider", "cc_common Section"},
+			merged: 1,
+		},
+		{
+			name:   "off",
+			dedupe: Dedupe{},
+			refs: []*reference{
+				ref("run", "Method", "a.html#run", "code"),
+				ref("Run", "Section", "a.html#run", "h2"),
+			},
+			want: []string{"run Method", "Run Section"},
+		},
+	} {
+		kept, merged := test.dedupe.dedupe(test.refs)
+		got := []string{}
+		for _, r := range kept {
+			got = append(got, r.name+" "+r.etype)
+		}
+		if !reflect.DeepEqual(got, test.want) || len(merged) != test.merged {
+			t.Errorf("%s: dedupe() kept %q and merged %q, want %q and %d merges", test.name, got, merged, test.want, test.merged)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxDescriptionLength is the longest description stored in the index.
+	maxDescriptionLength = 300
+	// maxMenuDescriptionLength is the longest description shown in Dash's
+	// menu in place of the page title.
+	maxMenuDescriptionLength = 100
+)
+
+// description returns a short summary for the entry at n: the text of its
+// descriptionNode.
+func (t *Transform) description(n *html.Node) string {
+	d := t.descriptionNode(n)
+	if d == nil {
+		return ""
+	}
+	return truncate(whitespaceRegexp.ReplaceAllString(text(d), " "), maxDescriptionLength)
+}
+
+// descriptionNode returns the first element after n that matches the
+// selector's description_css, as long as no other element matched by the
+// selector comes before it.
+func (t *Transform) descriptionNode(n *html.Node) *html.Node {
+	if t.DescriptionCss == nil {
+		return nil
+	}
+	for next := following(n); next != nil; next = nextInDocument(next) {
+		if next.Type != html.ElementNode {
+			continue
+		}
+		if t.CssSelector.Match(next) {
+			return nil
+		}
+		if t.DescriptionCss.Match(next) {
+			return next
+		}
+	}
+	return nil
+}
+
+// nextInDocument returns the node after n in document order.
+func nextInDocument(n *html.Node) *html.Node {
+	if n.FirstChild != nil {
+		return n.FirstChild
+	}
+	return following(n)
+}
+
+// following returns the node after n in document order, skipping n's children.
+func following(n *html.Node) *html.Node {
+	for ; n != nil; n = n.Parent {
+		if n.NextSibling != nil {
+			return n.NextSibling
+		}
+	}
+	return nil
+}
+
+// truncate shortens s to at most max bytes, cutting at a word boundary, or at
+// least between two runes.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	s = s[:max]
+	if i := strings.LastIndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	return strings.TrimRight(s, " ,.;:") + "…"
+}
+
+// menuDescription picks what Dash shows next to an entry: its description if
+// it is short enough and safe to embed in the index path, else the page title.
+// Status labels go in front, e.g. "[Deprecated] ...".
+func (r *reference) menuDescription() string {
+	description := r.description
+	if len(description) == 0 || len(description) > maxMenuDescriptionLength || strings.ContainsAny(description, "<>") {
+		description = r.pageTitle
+	}
+	for i := len(r.labels) - 1; i >= 0; i-- {
+		description = "[" + r.labels[i] + "] " + description
+	}
+	return description
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	css "github.com/andybalholm/cascadia"
+)
+
+func TestTruncate(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly ten", 11, "exactly ten"},
+		{"Keep going after errors.", 15, "Keep going…"},
+		{"Ends in punctuation, then more", 21, "Ends in punctuation…"},
+		{"nospacesatallhere", 6, "nospac…"},
+		{"日本語のテキスト", 7, "日本…"},
+		{"ab日本", 4, "ab…"},
+	} {
+		got := truncate(test.s, test.max)
+		if got != test.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", test.s, test.max, got, test.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q is not valid UTF-8", test.s, test.max, got)
+		}
+	}
+}
+
+func TestDescription(t *testing.T) {
+	top := parseTestHTML(t, `<dl>
+<dt><a id="a">--a</a></dt><dd><p>First   flag,
+spanning lines.</p><p>More.</p></dd>
+<dt><a id="b">--b</a></dt><dd></dd>
+<dt><a id="c">--c</a></dt><dd><p>Third flag.</p></dd>
+</dl>`)
+	sel := Transform{CssSelector: *testSelector("dt a"), DescriptionCss: testSelector("dd > p")}
+	for id, want := range map[string]string{
+		"a": "First flag, spanning lines.",
+		// The next p belongs to --c.
+		"b": "",
+		"c": "Third flag.",
+	} {
+		n := css.MustCompile("#" + id).MatchFirst(top)
+		if got := sel.description(n); got != want {
+			t.Errorf("description(#%s) = %q, want %q", id, got, want)
+		}
+	}
+
+	long := parseTestHTML(t, `<h2>x</h2><p>`+strings.Repeat("word ", 100)+`</p>`)
+	sel = Transform{CssSelector: *testSelector("h2"), DescriptionCss: testSelector("p")}
+	if got := sel.description(css.MustCompile("h2").MatchFirst(long)); len(got) > maxDescriptionLength+len("…") {
+		t.Errorf("description() is %d bytes long, want at most %d", len(got), maxDescriptionLength)
+	}
+}
+
+func TestMenuDescription(t *testing.T) {
+	for _, test := range []struct {
+		ref  reference
+		want string
+	}{
+		{reference{description: "Keep going.", pageTitle: "Command-Line Reference"}, "Keep going."},
+		{reference{pageTitle: "Command-Line Reference"}, "Command-Line Reference"},
+		{reference{description: strings.Repeat("x", maxMenuDescriptionLength+1), pageTitle: "Title"}, "Title"},
+		{reference{description: "Use <code>", pageTitle: "Title"}, "Title"},
+		{reference{description: "No-op.", labels: []string{"Experimental", "Deprecated"}}, "[Experimental] [Deprecated] No-op."},
+	} {
+		if got := test.ref.menuDescription(); got != test.want {
+			t.Errorf("menuDescription(%+v) = %q, want %q", test.ref, got, test.want)
+		}
+	}
+}
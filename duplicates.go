@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DuplicatePages describes how to find pages that are copies of another page.
+// Duplicates are left out of the docset and links to them are rewritten to
+// point at their canonical page.
+type DuplicatePages struct {
+	// Patterns map the path of a duplicate to the path of its canonical page.
+	Patterns []DuplicatePattern `yaml:"patterns"`
+	// ContentHash treats pages with byte-identical contents as duplicates.
+	ContentHash bool `yaml:"content_hash"`
+}
+
+// DuplicatePattern marks a page as a duplicate if its path matches Regex and
+// the path produced by expanding Canonical exists.
+type DuplicatePattern struct {
+	Regex     regexpYaml `yaml:"regex"`
+	Canonical string     `yaml:"canonical" expand:"-"`
+}
+
+func (d DuplicatePages) enabled() bool {
+	return len(d.Patterns) > 0 || d.ContentHash
+}
+
+// matchesPattern reports whether src looks like a duplicate by name alone.
+func (d DuplicatePages) matchesPattern(src string) bool {
+	for _, p := range d.Patterns {
+		if p.Regex.MatchString(src) {
+			return true
+		}
+	}
+	return false
+}
+
+// findDuplicates walks base and returns a map from each duplicate page to its
+// canonical page.
+func findDuplicates(base string, dashing Dashing) (map[string]string, error) {
+	pages := map[string]bool{}
+	err := filepath.Walk(base, func(src string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || dashing.shouldIgnoreFile(src) || !htmlish(src) {
+			return nil
+		}
+		pages[path.Clean(src)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := make([]string, 0, len(pages))
+	for p := range pages {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	dups := map[string]string{}
+	for _, src := range sorted {
+		for _, p := range dashing.DuplicatePages.Patterns {
+			if !p.Regex.MatchString(src) {
+				continue
+			}
+			canonical := path.Clean(p.Regex.ReplaceAllString(src, p.Canonical))
+			if canonical != src && pages[canonical] {
+				dups[src] = canonical
+				break
+			}
+		}
+	}
+
+	if dashing.DuplicatePages.ContentHash {
+		byHash := map[[sha256.Size]byte][]string{}
+		for _, src := range sorted {
+			if _, ok := dups[src]; ok {
+				continue
+			}
+			sum, err := hashFile(src)
+			if err != nil {
+				return nil, err
+			}
+			byHash[sum] = append(byHash[sum], src)
+		}
+		for _, group := range byHash {
+			if len(group) < 2 {
+				continue
+			}
+			canonical := dashing.DuplicatePages.pickCanonical(group)
+			for _, src := range group {
+				if src != canonical {
+					dups[src] = canonical
+				}
+			}
+		}
+	}
+
+	// A pattern's canonical page may itself be a duplicate. Follow the chain
+	// to a page that isn't. Pages in a cycle are kept as they are.
+	resolved := map[string]string{}
+	for _, src := range sorted {
+		if _, ok := dups[src]; !ok {
+			continue
+		}
+		canonical, err := followDuplicates(src, dups)
+		if err != nil {
+			fmt.Printf("Warning: %s; keeping %s\n", err, src)
+			continue
+		}
+		resolved[src] = canonical
+		fmt.Printf("Duplicate page %s is a copy of %s\n", src, canonical)
+	}
+	return resolved, nil
+}
+
+// followDuplicates follows the chain of duplicates from src to a page that
+// isn't one.
+func followDuplicates(src string, dups map[string]string) (string, error) {
+	chain := []string{src}
+	seen := map[string]bool{src: true}
+	for canonical, ok := dups[src]; ok; canonical, ok = dups[canonical] {
+		if seen[canonical] {
+			return "", fmt.Errorf("duplicate pages form a cycle: %s -> %s", strings.Join(chain, " -> "), canonical)
+		}
+		seen[canonical] = true
+		chain = append(chain, canonical)
+	}
+	return chain[len(chain)-1], nil
+}
+
+// pickCanonical chooses the page to keep from a group of identical pages. Pages
+// that look like duplicates by name lose, then the shortest path wins.
+func (d DuplicatePages) pickCanonical(group []string) string {
+	best := group[0]
+	for _, src := range group[1:] {
+		bestDup, srcDup := d.matchesPattern(best), d.matchesPattern(src)
+		switch {
+		case bestDup != srcDup:
+			if bestDup {
+				best = src
+			}
+		case len(src) != len(best):
+			if len(src) < len(best) {
+				best = src
+			}
+		case src < best:
+			best = src
+		}
+	}
+	return best
+}
+
+func hashFile(src string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(src)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// canonicalLink returns the link that should replace linkPath, a link found in
+// the page at src, or false if it does not point at a duplicate. Links
+// starting with "/" are relative to walk_root and stay that way.
+func (d *Dashing) canonicalLink(src, linkPath string) (string, bool) {
+	dir, prefix := path.Dir(src), ""
+	if strings.HasPrefix(linkPath, "/") {
+		dir, prefix = d.WalkRoot, "/"
+		if len(dir) == 0 {
+			dir = "."
+		}
+	}
+	canonical, ok := d.duplicates[path.Join(dir, linkPath)]
+	if !ok {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, canonical)
+	if err != nil {
+		return "", false
+	}
+	return prefix + filepath.ToSlash(rel), true
+}
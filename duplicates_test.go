@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func duplicatePattern(regex, canonical string) DuplicatePattern {
+	return DuplicatePattern{Regex: regexpYaml{regexp.MustCompile(regex)}, Canonical: canonical}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		files map[string]string
+		pages DuplicatePages
+		want  map[string]string
+	}{
+		{
+			name:  "pattern",
+			files: map[string]string{"foo.html": "a", "foo-2.html": "b", "bar-2.html": "c"},
+			pages: DuplicatePages{Patterns: []DuplicatePattern{duplicatePattern(`^(.*)-2\.html$`, "$1.html")}},
+			// bar.html doesn't exist, so bar-2.html is kept.
+			want: map[string]string{"foo-2.html": "foo.html"},
+		},
+		{
+			name:  "content hash prefers pages that don't match a pattern",
+			files: map[string]string{"a/x-2.html": "same", "b/longer.html": "same", "c.html": "other"},
+			pages: DuplicatePages{ContentHash: true, Patterns: []DuplicatePattern{duplicatePattern(`^(.*)-2\.html$`, "$1.html")}},
+			want:  map[string]string{"a/x-2.html": "b/longer.html"},
+		},
+		{
+			name:  "chain",
+			files: map[string]string{"a.html": "", "b.html": "", "c.html": ""},
+			pages: DuplicatePages{Patterns: []DuplicatePattern{
+				duplicatePattern(`^a\.html$`, "b.html"),
+				duplicatePattern(`^b\.html$`, "c.html"),
+			}},
+			want: map[string]string{"a.html": "c.html", "b.html": "c.html"},
+		},
+		{
+			name:  "cycle keeps the pages in it",
+			files: map[string]string{"a.html": "", "b.html": "", "c.html": "", "d.html": ""},
+			pages: DuplicatePages{Patterns: []DuplicatePattern{
+				duplicatePattern(`^a\.html$`, "b.html"),
+				duplicatePattern(`^b\.html$`, "c.html"),
+				duplicatePattern(`^c\.html$`, "b.html"),
+				duplicatePattern(`^d\.html$`, "a.html"),
+			}},
+			want: map[string]string{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, test.files)
+			wd, _ := os.Getwd()
+			defer os.Chdir(wd)
+			if err := os.Chdir(dir); err != nil {
+				t.Fatal(err)
+			}
+			got, err := findDuplicates(".", Dashing{DuplicatePages: test.pages})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("findDuplicates() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalLink(t *testing.T) {
+	d := &Dashing{WalkRoot: "site", duplicates: map[string]string{
+		"site/docs/foo-2.html": "site/docs/foo.html",
+		"site/bar-2.html":      "site/docs/bar.html",
+	}}
+	for _, test := range []struct {
+		src, link string
+		want      string
+		ok        bool
+	}{
+		{"site/docs/index.html", "foo-2.html", "foo.html", true},
+		{"site/docs/index.html", "../bar-2.html", "bar.html", true},
+		{"site/other/index.html", "/docs/foo-2.html", "/docs/foo.html", true},
+		{"site/other/index.html", "/bar-2.html", "/docs/bar.html", true},
+		{"site/docs/index.html", "foo.html", "", false},
+		{"site/docs/index.html", "/foo-2.html", "", false},
+	} {
+		got, ok := d.canonicalLink(test.src, test.link)
+		if got != test.want || ok != test.ok {
+			t.Errorf("canonicalLink(%q, %q) = %q, %v, want %q, %v", test.src, test.link, got, ok, test.want, test.ok)
+		}
+	}
+}
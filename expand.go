@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	css "github.com/andybalholm/cascadia"
+)
+
+// envRegexp matches ${NAME} and ${NAME:-default}.
+var envRegexp = regexp.MustCompile(`\$\{(\w+)(?::-([^}]*))?\}`)
+
+// expandValues expands ${ENV} references and templates such as
+// {{ .Version }} in every string of the config, including CSS selectors and
+// info_plist values, then resolves file paths that are globs, such as
+// "www.gstatic.com/**/favicon-prod.png", to the file they match. Fields
+// tagged `expand:"-"` are replacement templates used at build time and are
+// left alone, as are regexps; fields tagged `expand:"file"` may be globs.
+func (d *Dashing) expandValues() error {
+	data := map[string]interface{}{
+		"Version": d.docsetVersion,
+		"Env":     environ(),
+	}
+
+	files := []*string{}
+	if err := expandFields(reflect.ValueOf(d).Elem(), data, &files); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if len(*f) == 0 || !strings.ContainsAny(*f, "*?[") {
+			continue
+		}
+		// Globs are relative to the config file, but build_all_docs.sh runs
+		// the shared config.yaml from each scraped site's directory, so fall
+		// back to the working directory. Leave unmatched globs for the code
+		// using the file to report.
+		match, err := resolveGlob(*f, d.configDir)
+		if err != nil && !filepath.IsAbs(*f) {
+			if cwdMatch, cwdErr := resolveGlob(*f, ""); cwdErr == nil {
+				match, err = cwdMatch, nil
+			}
+		}
+		if err != nil {
+			fmt.Printf("Warning: %s\n", err)
+			continue
+		}
+		*f = match
+	}
+	return nil
+}
+
+var (
+	cssSelectorYamlType = reflect.TypeOf(cssSelectorYaml{})
+	dashingPkgPath      = reflect.TypeOf(Dashing{}).PkgPath()
+)
+
+// expandFields expands the strings in v, which must be settable, and adds
+// the fields tagged `expand:"file"` to files. It only descends into the
+// config's own types, not into parsed regexps or templates.
+func expandFields(v reflect.Value, data interface{}, files *[]*string) error {
+	switch v.Kind() {
+	case reflect.String:
+		expanded, err := expandValue(v.String(), data)
+		if err != nil {
+			return err
+		}
+		v.SetString(expanded)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return expandFields(v.Elem(), data, files)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := expandFields(v.Index(i), data, files); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			expanded, err := expandInterface(v.MapIndex(k).Interface(), data)
+			if err != nil {
+				return err
+			}
+			if expanded != nil {
+				v.SetMapIndex(k, reflect.ValueOf(expanded))
+			}
+		}
+	case reflect.Struct:
+		if v.Type() == cssSelectorYamlType {
+			return v.Addr().Interface().(*cssSelectorYaml).expand(data)
+		}
+		if v.Type().PkgPath() != dashingPkgPath {
+			return nil
+		}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			tag := f.Tag.Get("expand")
+			if !f.IsExported() || tag == "-" {
+				continue
+			}
+			if err := expandFields(v.Field(i), data, files); err != nil {
+				return err
+			}
+			if tag == "file" {
+				*files = append(*files, v.Field(i).Addr().Interface().(*string))
+			}
+		}
+	}
+	return nil
+}
+
+// expandInterface expands the strings in a map value, such as an info_plist
+// value. Values that aren't strings, lists or maps of them are kept as they
+// are.
+func expandInterface(x interface{}, data interface{}) (interface{}, error) {
+	switch x := x.(type) {
+	case string:
+		return expandValue(x, data)
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			expanded, err := expandInterface(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = expanded
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			expanded, err := expandInterface(item, data)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = expanded
+		}
+		return out, nil
+	}
+	return x, nil
+}
+
+// expand expands the selector as written and parses the result.
+func (c *cssSelectorYaml) expand(data interface{}) error {
+	expanded, err := expandValue(c.raw, data)
+	if err != nil || (c.Sel != nil && expanded == c.raw) {
+		return err
+	}
+	selector, err := css.Parse(expanded)
+	if err != nil {
+		return fmt.Errorf("invalid CSS selector '%s': %w", expanded, err)
+	}
+	c.Sel, c.raw = selector, expanded
+	return nil
+}
+
+// environ returns the environment as a map.
+func environ() map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// expandValue replaces ${NAME} with the environment variable NAME (or the
+// default given as ${NAME:-default}) and then evaluates s as a template.
+func expandValue(s string, data interface{}) (string, error) {
+	var missing []string
+	s = envRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envRegexp.FindStringSubmatch(ref)
+		if v, ok := os.LookupEnv(m[1]); ok {
+			return v
+		}
+		if strings.Contains(ref, ":-") {
+			return m[2]
+		}
+		missing = append(missing, m[1])
+		return ref
+	})
+	if len(missing) > 0 {
+		return s, fmt.Errorf("'%s': environment variable %s is not set", s, strings.Join(missing, ", "))
+	}
+
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	t, err := template.New("value").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return s, fmt.Errorf("invalid template '%s': %w", s, err)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return s, fmt.Errorf("invalid template '%s': %w", s, err)
+	}
+	return b.String(), nil
+}
+
+// resolveGlob returns the file matching pattern, where "*" matches within
+// a directory and "**" matches any number of directories. Relative patterns
+// are relative to dir, the directory of the config file. If several files
+// match, the first in lexical order wins.
+func resolveGlob(pattern, dir string) (string, error) {
+	if !filepath.IsAbs(pattern) && len(dir) > 0 {
+		pattern = filepath.Join(dir, pattern)
+	}
+	pattern = filepath.ToSlash(pattern)
+	root := "."
+	if i := strings.IndexAny(pattern, "*?["); i > 0 {
+		if j := strings.LastIndex(pattern[:i], "/"); j >= 0 {
+			root = pattern[:j]
+			if len(root) == 0 {
+				root = "/"
+			}
+		}
+	}
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	matches := []string{}
+	err = filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && re.MatchString(filepath.ToSlash(p)) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("resolving '%s': %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no file matches '%s'", pattern)
+	}
+	sort.Strings(matches)
+	if len(matches) > 1 {
+		fmt.Printf("%d files match '%s', using %s\n", len(matches), pattern, matches[0])
+	}
+	return matches[0], nil
+}
+
+// globRegexp translates a glob into a regexp matching whole paths.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	if strings.HasPrefix(pattern, "./") {
+		pattern = pattern[2:]
+		b.WriteString(`(\./)?`)
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**/") {
+				b.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pattern[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid glob '%s': unterminated '['", pattern)
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandValue(t *testing.T) {
+	t.Setenv("DASHING_TEST_RULES", "rules_go")
+	data := map[string]interface{}{"Version": "7.1.0"}
+	for _, test := range []struct {
+		s, want string
+		err     bool
+	}{
+		{"docs/${DASHING_TEST_RULES}", "docs/rules_go", false},
+		{"${DASHING_TEST_UNSET:-bazel}", "bazel", false},
+		{"${DASHING_TEST_UNSET}", "", true},
+		{"Bazel {{ .Version }}", "Bazel 7.1.0", false},
+		{"{{ .Missing }}", "", true},
+		{"{{ .Version", "", true},
+		{"$1.html", "$1.html", false},
+	} {
+		got, err := expandValue(test.s, data)
+		if (err != nil) != test.err {
+			t.Errorf("expandValue(%q) error = %v, want error %v", test.s, err, test.err)
+		} else if !test.err && got != test.want {
+			t.Errorf("expandValue(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{"www.gstatic.com/**/favicon-prod.png", []string{"www.gstatic.com/favicon-prod.png", "www.gstatic.com/a/b/favicon-prod.png"}, []string{"www.gstatic.com/a/favicon.png", "wwwxgstatic.com/favicon-prod.png"}},
+		{"icons/*.svg", []string{"icons/bazel.svg"}, []string{"icons/a/bazel.svg"}},
+		{"icon?.[!x]ng", []string{"icon1.png"}, []string{"icon1.xng", "icon12.png"}},
+		{"./icon.png", []string{"icon.png", "./icon.png"}, nil},
+	} {
+		re, err := globRegexp(test.pattern)
+		if err != nil {
+			t.Fatalf("globRegexp(%q): %s", test.pattern, err)
+		}
+		for _, p := range test.match {
+			if !re.MatchString(p) {
+				t.Errorf("globRegexp(%q) doesn't match %q", test.pattern, p)
+			}
+		}
+		for _, p := range test.noMatch {
+			if re.MatchString(p) {
+				t.Errorf("globRegexp(%q) matches %q", test.pattern, p)
+			}
+		}
+	}
+	if _, err := globRegexp("icon[.png"); err == nil {
+		t.Error("globRegexp() accepted an unterminated '['")
+	}
+}
+
+func TestResolveGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"www.gstatic.com/b/favicon-prod.png": "",
+		"www.gstatic.com/a/favicon-prod.png": "",
+		"icon.svg":                           "",
+	})
+	for _, test := range []struct {
+		pattern, want string
+	}{
+		// Several matches: the first in lexical order wins.
+		{"www.gstatic.com/**/favicon-prod.png", filepath.Join(dir, "www.gstatic.com/a/favicon-prod.png")},
+		{"*.svg", filepath.Join(dir, "icon.svg")},
+		{filepath.Join(dir, "*.svg"), filepath.Join(dir, "icon.svg")},
+	} {
+		got, err := resolveGlob(test.pattern, dir)
+		if err != nil {
+			t.Errorf("resolveGlob(%q): %s", test.pattern, err)
+		} else if got != test.want {
+			t.Errorf("resolveGlob(%q) = %q, want %q", test.pattern, got, test.want)
+		}
+	}
+	if _, err := resolveGlob("*.png", dir); err == nil {
+		t.Error("resolveGlob() of a pattern matching nothing succeeded")
+	}
+}
+
+func TestExpandValues(t *testing.T) {
+	t.Setenv("DASHING_TEST_NAME", "Bazel")
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"www.gstatic.com/x/favicon-prod.png": ""})
+	// The shared config.yaml is used from the scraped site's directory.
+	site := t.TempDir()
+	writeFiles(t, site, map[string]string{"images/x/icon@2x.png": ""})
+	t.Chdir(site)
+	var d Dashing
+	err := yaml.Unmarshal([]byte(`
+name: ${DASHING_TEST_NAME}
+package: '{{ .Version }}'
+keyword: ${DASHING_TEST_NAME}
+web_search_keyword: ${DASHING_TEST_NAME}
+icon32x32: www.gstatic.com/**/favicon-prod.png
+icon64x64: images/**/icon@2x.png
+css_selector_for_body: '${DASHING_TEST_BODY:-main}'
+type_aliases:
+  Rule: ${DASHING_TEST_TYPE:-Class}
+title_rewrites:
+  - regex: '^(.*) \| Bazel$'
+    replace: '${1}'
+duplicate_pages:
+  patterns:
+    - regex: '^(.*)-2\.html$'
+      canonical: '${1}.html'
+selectors:
+  - css: 'h2.${DASHING_TEST_NAME}'
+    type: Section
+    name_replace: '${1}'
+    aliases:
+      - regex: '^actions\.(.+)$'
+        template: 'ctx.actions.${1}'
+    search_prefix:
+      - literal: '${DASHING_TEST_NAME}'
+info_plist:
+  DocSetPlatformFamily: ${DASHING_TEST_NAME}
+  isJavaScriptEnabled: true
+  nested: ['${DASHING_TEST_NAME}', 1]
+  empty: ~
+sources:
+  - name: ${DASHING_TEST_NAME}
+    markdown:
+      template: ${DASHING_TEST_TEMPLATE:-page.tmpl}
+`), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.docsetVersion = "7.1.0"
+	d.configDir = dir
+	if err := d.expandValues(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		field     string
+		got, want interface{}
+	}{
+		{"name", d.Name, "Bazel"},
+		{"package", d.Package, "7.1.0"},
+		{"keyword", d.Keyword, "Bazel"},
+		{"web_search_keyword", d.WebSearchKeyword, "Bazel"},
+		{"icon32x32", d.Icon32x32, filepath.Join(dir, "www.gstatic.com/x/favicon-prod.png")},
+		{"icon64x64", d.Icon64x64, "images/x/icon@2x.png"},
+		{"css_selector_for_body", d.CssSelectorForBody.String(), "main"},
+		{"type_aliases", d.TypeAliases["Rule"], "Class"},
+		{"selectors.css", d.Selectors[0].CssSelector.String(), "h2.Bazel"},
+		{"search_prefix.literal", d.Selectors[0].SearchPrefix[0].Literal, "Bazel"},
+		{"info_plist", d.InfoPlist["DocSetPlatformFamily"], "Bazel"},
+		{"info_plist nested", d.InfoPlist["nested"], []interface{}{"Bazel", 1}},
+		{"info_plist bool", d.InfoPlist["isJavaScriptEnabled"], true},
+		{"sources.name", d.Sources[0].Name, "Bazel"},
+		{"sources.markdown.template", d.Sources[0].Markdown.Template, "page.tmpl"},
+		// Replacement templates are expanded at build time instead.
+		{"replace", d.TitleRewrites[0].Replace, "${1}"},
+		{"canonical", d.DuplicatePages.Patterns[0].Canonical, "${1}.html"},
+		{"name_replace", d.Selectors[0].NameReplace, "${1}"},
+		{"aliases.template", d.Selectors[0].Aliases[0].Template, "ctx.actions.${1}"},
+	} {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s = %#v, want %#v", test.field, test.got, test.want)
+		}
+	}
+	if _, ok := d.InfoPlist["empty"]; !ok {
+		t.Error("expandValues() dropped an empty info_plist value")
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	css "github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// FlagExport configures extracting Bazel's command-line flags from the
+// command-line reference into structured data.
+type FlagExport struct {
+	// MatchPath selects the command-line reference pages.
+	MatchPath *regexpYaml `yaml:"matchpath"`
+}
+
+// flagInfo describes one command-line flag.
+type flagInfo struct {
+	Name         string   `json:"name"`
+	Command      string   `json:"command"`
+	Abbreviation string   `json:"abbreviation,omitempty"`
+	Boolean      bool     `json:"boolean"`
+	Value        string   `json:"value,omitempty"`
+	Default      string   `json:"default,omitempty"`
+	Multiple     bool     `json:"multiple"`
+	Description  string   `json:"description"`
+	EffectTags   []string `json:"effect_tags"`
+	MetadataTags []string `json:"metadata_tags"`
+	Deprecated   bool     `json:"deprecated"`
+	Experimental bool     `json:"experimental"`
+	Page         string   `json:"page"`
+}
+
+type flagSet struct {
+	Version string     `json:"version"`
+	Flags   []flagInfo `json:"flags"`
+	// Inherits lists the commands whose options each command also takes.
+	Inherits map[string][]string `json:"inherits,omitempty"`
+}
+
+var (
+	flagValueRegexp      = regexp.MustCompile(`^=(<[^>]*>|\S+)`)
+	flagAbbrevRegexp     = regexp.MustCompile(`\[(-\w+)\]`)
+	flagDefaultRegexp    = regexp.MustCompile(`default: (?:"(.*)"|(.+))$`)
+	flagDeprecatedRegexp = regexp.MustCompile(`(?i)\bdeprecated\b|\bno-op\b`)
+	flagOptionalRegexp   = regexp.MustCompile(`^--\[no\]`)
+	flagInheritsRegexp   = regexp.MustCompile(`(?i)inherits all options from ([^.]+)`)
+)
+
+// flagName strips the "--[no]" and "--no" spellings of a flag down to "--name".
+func flagName(raw string) (name string, boolean bool) {
+	if flagOptionalRegexp.MatchString(raw) {
+		return "--" + raw[len("--[no]"):], true
+	}
+	return raw, false
+}
+
+// experimental reports whether a flag is experimental by name or tag.
+func (f flagInfo) experimental() bool {
+	if strings.HasPrefix(f.Name, "--experimental_") {
+		return true
+	}
+	for _, t := range f.MetadataTags {
+		if t == "experimental" {
+			return true
+		}
+	}
+	return false
+}
+
+func (f flagInfo) deprecated() bool {
+	for _, t := range f.MetadataTags {
+		if t == "deprecated" {
+			return true
+		}
+	}
+	return flagDeprecatedRegexp.MatchString(f.Description)
+}
+
+// extractFlags reads the flags documented on a command-line reference page.
+// Each flag is a <dt id="flag--name"> followed by a <dd> with its description
+// and tags, under the <h2> of the command it belongs to.
+func extractFlags(top *html.Node, page string) []flagInfo {
+	flags := []flagInfo{}
+	command := ""
+	for n := range top.Descendants() {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		if n.DataAtom == atom.H2 {
+			command = commandName(n)
+			continue
+		}
+		id := attr(n, "id")
+		if n.DataAtom != atom.Dt || !strings.HasPrefix(id, "flag--") {
+			continue
+		}
+
+		link := css.MustCompile("a[href]").MatchFirst(n)
+		if link == nil {
+			continue
+		}
+		f := flagInfo{Command: command, Page: page + "#" + id, EffectTags: []string{}, MetadataTags: []string{}}
+		f.Name, f.Boolean = flagName(text(link))
+
+		dt := whitespaceRegexp.ReplaceAllString(text(n), " ")
+		rest := strings.TrimPrefix(dt, text(link))
+		if m := flagValueRegexp.FindStringSubmatch(rest); m != nil {
+			f.Value = m[1]
+		}
+		if m := flagAbbrevRegexp.FindStringSubmatch(rest); m != nil {
+			f.Abbreviation = m[1]
+		}
+		if m := flagDefaultRegexp.FindStringSubmatch(rest); m != nil {
+			f.Default = m[1] + m[2]
+		}
+		f.Multiple = strings.Contains(rest, "multiple uses are accumulated")
+
+		if dd := nextElementSibling(n); dd != nil && dd.DataAtom == atom.Dd {
+			if p := css.MustCompile("p").MatchFirst(dd); p != nil {
+				f.Description = whitespaceRegexp.ReplaceAllString(text(p), " ")
+			}
+			for _, a := range css.MustCompile("a[href]").MatchAll(dd) {
+				href := attr(a, "href")
+				switch {
+				case strings.HasPrefix(href, "#effect_tag_"):
+					f.EffectTags = append(f.EffectTags, strings.ToLower(text(a)))
+				case strings.HasPrefix(href, "#metadata_tag_"):
+					f.MetadataTags = append(f.MetadataTags, strings.ToLower(text(a)))
+				}
+			}
+		}
+		f.Deprecated = f.deprecated()
+		f.Experimental = f.experimental()
+		flags = append(flags, f)
+	}
+	return flags
+}
+
+// extractInherits reads which commands take all options of other commands,
+// from the "Inherits all options from build." note under each <h2>.
+func extractInherits(top *html.Node) map[string][]string {
+	inherits := map[string][]string{}
+	command := ""
+	for n := range top.Descendants() {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		if n.DataAtom == atom.H2 {
+			command = commandName(n)
+			continue
+		}
+		if n.DataAtom != atom.P || len(command) == 0 {
+			continue
+		}
+		m := flagInheritsRegexp.FindStringSubmatch(text(n))
+		if m == nil {
+			continue
+		}
+		for _, c := range strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' }) {
+			if c != "and" {
+				inherits[command] = append(inherits[command], c)
+			}
+		}
+	}
+	return inherits
+}
+
+// commandName returns the command an <h2> of the command-line reference is
+// about, e.g. "build" for "Build Options", "common" for the options common to
+// all commands and "startup" for startup options.
+func commandName(h2 *html.Node) string {
+	name := attr(h2, "id")
+	if len(name) == 0 {
+		name = strings.ReplaceAll(strings.ToLower(text(h2)), " ", "_")
+	}
+	switch {
+	case strings.Contains(name, "common"):
+		return "common"
+	case strings.Contains(name, "startup") || strings.Contains(name, "before_the_command"):
+		return "startup"
+	}
+	return strings.TrimSuffix(name, "_options")
+}
+
+func nextElementSibling(n *html.Node) *html.Node {
+	for n = n.NextSibling; n != nil; n = n.NextSibling {
+		if n.Type == html.ElementNode {
+			return n
+		}
+	}
+	return nil
+}
+
+// docsetFlags extracts the flags from the command-line reference pages of a
+// built docset.
+func docsetFlags(docset string, dashing Dashing) (flagSet, error) {
+	set := flagSet{Version: dashing.docsetVersion, Flags: []flagInfo{}, Inherits: map[string][]string{}}
+	if dashing.FlagExport.MatchPath == nil {
+		return set, fmt.Errorf("flag_export.matchpath is not configured")
+	}
+	docs := filepath.Join(docset, "Contents/Resources/Documents")
+	err := filepath.Walk(docs, func(src string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(docs, src)
+		if err != nil || info.IsDir() || !htmlish(src) || !dashing.FlagExport.MatchPath.MatchString(rel) {
+			return err
+		}
+		r, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		top, err := html.Parse(r)
+		if err != nil {
+			return err
+		}
+		set.Flags = append(set.Flags, extractFlags(top, filepath.ToSlash(rel))...)
+		for command, from := range extractInherits(top) {
+			set.Inherits[command] = from
+		}
+		return nil
+	})
+	return set, err
+}
+
+// exportFlags writes the flags of a built docset next to it in each of the
+// given formats ("json" or "csv").
+func exportFlags(docset string, dashing Dashing, formats []string) error {
+	set, err := docsetFlags(docset, dashing)
+	if err != nil {
+		return err
+	}
+	base := filepath.Join(filepath.Dir(filepath.Clean(docset)), dashing.Package+"-flags")
+	for _, format := range formats {
+		dest := base + "." + format
+		switch format {
+		case "json":
+			b, err := json.MarshalIndent(set, "", "  ")
+			if err != nil {
+				return err
+			}
+			err = os.WriteFile(dest, append(b, '\n'), 0644)
+		case "csv":
+			err = writeFlagsCSV(dest, set.Flags)
+		default:
+			return fmt.Errorf("unknown flag export format '%s'", format)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d flags to %s\n", len(set.Flags), dest)
+	}
+	return nil
+}
+
+func writeFlagsCSV(dest string, flags []flagInfo) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := csv.NewWriter(out)
+	w.Write([]string{"name", "command", "abbreviation", "boolean", "value", "default", "multiple", "effect_tags", "metadata_tags", "deprecated", "experimental", "page", "description"})
+	for _, f := range flags {
+		w.Write([]string{
+			f.Name, f.Command, f.Abbreviation, strconv.FormatBool(f.Boolean), f.Value, f.Default,
+			strconv.FormatBool(f.Multiple), strings.Join(f.EffectTags, " "), strings.Join(f.MetadataTags, " "),
+			strconv.FormatBool(f.Deprecated), strconv.FormatBool(f.Experimental), f.Page, f.Description,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
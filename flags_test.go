@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	css "github.com/andybalholm/cascadia"
+)
+
+// flagsPage is an excerpt of Bazel's command-line-reference.html.
+const flagsPage = `<html><body>
+<h2><a name="common_options">Options Common to all Commands</a></h2>
+<dl>
+<dt id="flag--keep_going"><code><a href="#flag--keep_going">--[no]keep_going</a></code> [<code>-k</code>] default: "false"</dt>
+<dd><p>Continue as much as possible after an error.</p>
+<p>Tags: <a href="#effect_tag_EAGERNESS_TO_EXIT"><code>eagerness_to_exit</code></a></p></dd>
+</dl>
+<h2 id="build">Build Options</h2>
+<dl>
+<dt id="flag--copt"><code><a href="#flag--copt">--copt</a>=&lt;a string&gt;</code> multiple uses are accumulated</dt>
+<dd><p>Additional options to pass to gcc.</p>
+<p>Tags: <a href="#effect_tag_ACTION_COMMAND_LINES"><code>action_command_lines</code></a>, <a href="#effect_tag_AFFECTS_OUTPUTS"><code>affects_outputs</code></a></p></dd>
+<dt id="flag--experimental_foo"><code><a href="#flag--experimental_foo">--experimental_foo</a>=&lt;an integer&gt;</code> default: "3"</dt>
+<dd><p>Deprecated: no-op.</p>
+<p>Tags: <a href="#metadata_tag_EXPERIMENTAL"><code>experimental</code></a></p></dd>
+</dl>
+</body></html>`
+
+var flagsPageWant = []flagInfo{
+	{
+		Name: "--keep_going", Command: "common", Abbreviation: "-k", Boolean: true, Default: "false",
+		Description: "Continue as much as possible after an error.", EffectTags: []string{"eagerness_to_exit"}, MetadataTags: []string{},
+		Page: "cmd.html#flag--keep_going",
+	},
+	{
+		Name: "--copt", Command: "build", Value: "<a string>", Multiple: true,
+		Description: "Additional options to pass to gcc.", EffectTags: []string{"action_command_lines", "affects_outputs"}, MetadataTags: []string{},
+		Page: "cmd.html#flag--copt",
+	},
+	{
+		Name: "--experimental_foo", Command: "build", Value: "<an integer>", Default: "3",
+		Description: "Deprecated: no-op.", EffectTags: []string{}, MetadataTags: []string{"experimental"},
+		Deprecated: true, Experimental: true, Page: "cmd.html#flag--experimental_foo",
+	},
+}
+
+func TestFlagName(t *testing.T) {
+	for _, test := range []struct {
+		raw, name string
+		boolean   bool
+	}{
+		{"--[no]keep_going", "--keep_going", true},
+		{"--copt", "--copt", false},
+		{"--noseparator", "--noseparator", false},
+	} {
+		if name, boolean := flagName(test.raw); name != test.name || boolean != test.boolean {
+			t.Errorf("flagName(%q) = %q, %v, want %q, %v", test.raw, name, boolean, test.name, test.boolean)
+		}
+	}
+}
+
+func TestExtractFlags(t *testing.T) {
+	got := extractFlags(parseTestHTML(t, flagsPage), "cmd.html")
+	if !reflect.DeepEqual(got, flagsPageWant) {
+		t.Errorf("extractFlags() =\n%+v\nwant\n%+v", got, flagsPageWant)
+	}
+}
+
+func TestExportFlags(t *testing.T) {
+	dir := t.TempDir()
+	docset := filepath.Join(dir, "bazel.docset")
+	writeFiles(t, filepath.Join(docset, "Contents/Resources/Documents"), map[string]string{
+		"docs/cmd.html":   flagsPage,
+		"docs/guide.html": flagsPage,
+	})
+	d := Dashing{Package: "bazel", FlagExport: FlagExport{MatchPath: testRegexp(`cmd\.html$`)}, docsetVersion: "7.1.0"}
+	if err := exportFlags(docset, d, []string{"json", "csv"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "bazel-flags.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set flagSet
+	if err := json.Unmarshal(b, &set); err != nil {
+		t.Fatal(err)
+	}
+	want := flagSet{Version: "7.1.0"}
+	for _, f := range flagsPageWant {
+		f.Page = "docs/" + f.Page
+		want.Flags = append(want.Flags, f)
+	}
+	if !reflect.DeepEqual(set, want) {
+		t.Errorf("bazel-flags.json =\n%+v\nwant\n%+v", set, want)
+	}
+
+	r, err := os.Open(filepath.Join(dir, "bazel-flags.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1+len(want.Flags) {
+		t.Fatalf("bazel-flags.csv has %d rows, want a header and %d flags", len(rows), len(want.Flags))
+	}
+	if got := rows[2][:7]; !reflect.DeepEqual(got, []string{"--copt", "build", "", "false", "<a string>", "", "true"}) {
+		t.Errorf("--copt row = %q", got)
+	}
+
+	if err := exportFlags(docset, d, []string{"xml"}); err == nil {
+		t.Error("exportFlags() accepted an unknown format")
+	}
+}
+
+func TestCommandName(t *testing.T) {
+	for _, test := range []struct {
+		h2, want string
+	}{
+		{`<h2 id="build">Build Options</h2>`, "build"},
+		{`<h2>Canonicalize-flags Options</h2>`, "canonicalize-flags"},
+		{`<h2><a name="common_options">Options Common to all Commands</a></h2>`, "common"},
+		{`<h2>Options that appear before the command and are parsed by the client:</h2>`, "startup"},
+	} {
+		h2 := css.MustCompile("h2").MatchFirst(parseTestHTML(t, test.h2))
+		if got := commandName(h2); got != test.want {
+			t.Errorf("commandName(%s) = %q, want %q", test.h2, got, test.want)
+		}
+	}
+}
+
+func TestExtractInherits(t *testing.T) {
+	top := parseTestHTML(t, `<h2 id="build">Build Options</h2><p>Options for builds.</p>
+<h2 id="test">Test Options</h2><p>Inherits all options from build.</p>
+<h2 id="coverage">Coverage Options</h2><p>Inherits all options from test, build and common.</p>`)
+	want := map[string][]string{"test": {"build"}, "coverage": {"test", "build", "common"}}
+	if got := extractInherits(top); !reflect.DeepEqual(got, want) {
+		t.Errorf("extractInherits() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	css "github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// initPageText creates the pageText table, a full-text index of the text of
+// each page as written to the docset. Dash ignores it; `query --text` uses
+// it, as does `serve`. It is an FTS5 table, so dashing must be built with
+// -tags sqlite_fts5.
+func initPageText(db *sql.DB) error {
+	_, err := db.Exec(`CREATE VIRTUAL TABLE pageText USING fts5(path UNINDEXED, title, body)`)
+	if err != nil && strings.Contains(err.Error(), "no such module") {
+		return fmt.Errorf("SQLite was built without FTS5; rebuild dashing with -tags sqlite_fts5")
+	}
+	return err
+}
+
+// insertPageText adds the page at path to the pageText table.
+func insertPageText(db *sql.DB, path string, top *html.Node) error {
+	title := ""
+	if t := css.MustCompile("title").MatchFirst(top); t != nil {
+		title = strings.TrimSpace(text(t))
+	}
+	body := top
+	if b := css.MustCompile("body").MatchFirst(top); b != nil {
+		body = b
+	}
+	var b strings.Builder
+	visibleText(body, &b)
+	_, err := db.Exec(`INSERT INTO pageText(path, title, body) VALUES (?,?,?)`, path, title, strings.TrimSpace(whitespaceRegexp.ReplaceAllString(b.String(), " ")))
+	return err
+}
+
+// visibleText writes the text under n that a reader would see, leaving out
+// scripts and styles.
+func visibleText(n *html.Node, b *strings.Builder) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		b.WriteString(" ")
+		return
+	case html.ElementNode:
+		switch n.Data {
+		case "script", "style", "noscript", "template":
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		visibleText(c, b)
+	}
+}
+
+// checkPageText fails if the docset has no pageText table.
+func checkPageText(db *sql.DB) error {
+	var name string
+	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE name = 'pageText'`).Scan(&name)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("the docset has no page text index; build it with --fts")
+	}
+	return err
+}
+
+// pageMatch is a page whose text matches a search.
+type pageMatch struct {
+	path, title string
+	// snippet is the matching text, with the matches in [brackets].
+	snippet string
+}
+
+// searchPageText finds the pages whose text matches term, an SQLite
+// full-text query, best match first.
+func searchPageText(db *sql.DB, term string, limit int) ([]pageMatch, error) {
+	if err := checkPageText(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT path, title, snippet(pageText, 2, '[', ']', '…', 12) FROM pageText WHERE pageText MATCH ? ORDER BY rank LIMIT ?`, term, limit)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			err = fmt.Errorf("%w (rebuild dashing with -tags sqlite_fts5)", err)
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []pageMatch{}
+	for rows.Next() {
+		var m pageMatch
+		if err := rows.Scan(&m.path, &m.title, &m.snippet); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVisibleText(t *testing.T) {
+	for _, test := range []struct {
+		page, want string
+	}{
+		{`<p>remote <b>caching</b></p>`, "remote caching"},
+		{`<p>a</p><script>var x = 1;</script><style>p {}</style><p>b</p>`, "a b"},
+		{`<noscript>enable JavaScript</noscript><template><p>t</p></template>c`, "c"},
+	} {
+		var b strings.Builder
+		visibleText(parseTestHTML(t, test.page), &b)
+		if got := strings.Join(strings.Fields(b.String()), " "); got != test.want {
+			t.Errorf("visibleText(%q) = %q, want %q", test.page, got, test.want)
+		}
+	}
+}
+
+// ftsPages are pages for the page text index, keyed by path.
+var ftsPages = map[string]string{
+	"remote.html":  `<html><head><title>Remote Caching</title></head><body><p>Share build outputs with a remote cache.</p><script>var cache = 1;</script></body></html>`,
+	"sandbox.html": `<html><head><title>Sandboxing</title></head><body><p>Actions run in a sandbox.</p></body></html>`,
+}
+
+// writePageText builds a docset of ftsPages with a pageText table, or skips
+// the test if SQLite was built without FTS5.
+func writePageText(t *testing.T) string {
+	t.Helper()
+	docset := writeDocset(t, t.TempDir(), ftsPages, nil)
+	db, err := sql.Open("sqlite3", filepath.Join(docset, "Contents/Resources/docSet.dsidx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := initPageText(db); err != nil {
+		if strings.Contains(err.Error(), "FTS5") {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+	for path, page := range ftsPages {
+		if err := insertPageText(db, path, parseTestHTML(t, page)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return docset
+}
+
+func TestSearchPageText(t *testing.T) {
+	db, err := openIndex(writePageText(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, test := range []struct {
+		term, want, snippet string
+	}{
+		{"cache", "remote.html", "Share build outputs with a remote [cache]."},
+		{"cach*", "remote.html", ""},
+		{"sandbox", "sandbox.html", ""},
+		{"title:sandboxing", "sandbox.html", ""},
+		// Script text isn't indexed.
+		{"var", "", ""},
+	} {
+		matches, err := searchPageText(db, test.term, 10)
+		if err != nil {
+			t.Errorf("searchPageText(%q): %s", test.term, err)
+			continue
+		}
+		got := []string{}
+		for _, m := range matches {
+			got = append(got, m.path)
+		}
+		if strings.Join(got, ",") != test.want {
+			t.Errorf("searchPageText(%q) = %q, want %q", test.term, got, test.want)
+		}
+		if len(test.snippet) > 0 && len(matches) > 0 && matches[0].snippet != test.snippet {
+			t.Errorf("searchPageText(%q) snippet = %q, want %q", test.term, matches[0].snippet, test.snippet)
+		}
+	}
+}
+
+func TestSearchPageTextWithoutIndex(t *testing.T) {
+	db, err := openIndex(writeDocset(t, t.TempDir(), ftsPages, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := searchPageText(db, "cache", 10); err == nil || !strings.Contains(err.Error(), "build it with --fts") {
+		t.Errorf("searchPageText() without pageText = %v, want an error about --fts", err)
+	}
+}
+
+func TestPreviewHandler(t *testing.T) {
+	docset := writePageText(t)
+	db, err := openIndex(docset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	h := previewHandler(docset, db)
+	for _, test := range []struct {
+		url      string
+		code     int
+		contains []string
+	}{
+		{"/remote.html", 200, []string{"<title>Remote Caching</title>"}},
+		{"/search?q=cache", 200, []string{"1 pages", `<a href="/remote.html">Remote Caching</a>`, "remote [cache]."}},
+		{"/search?q=%22%3Cb%3E%22", 200, []string{`value="&#34;&lt;b&gt;&#34;"`, "0 pages"}},
+		{"/search", 200, []string{`<input name="q" value="">`}},
+		{"/search?q=%22unterminated", 500, nil},
+		{"/missing.html", 404, nil},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", test.url, nil))
+		body, _ := io.ReadAll(w.Result().Body)
+		if w.Code != test.code {
+			t.Errorf("GET %s = %d, want %d", test.url, w.Code, test.code)
+		}
+		for _, s := range test.contains {
+			if !strings.Contains(string(body), s) {
+				t.Errorf("GET %s = %q, want it to contain %q", test.url, body, s)
+			}
+		}
+	}
+}
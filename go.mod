@@ -5,13 +5,19 @@ go 1.24
 require (
 	github.com/andybalholm/cascadia v1.3.3
 	github.com/mattn/go-sqlite3 v2.0.1+incompatible
+	github.com/russross/blackfriday/v2 v2.0.1
+	github.com/srwiley/oksvg v0.0.0-20221011165216-be6e8873101c
+	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef
 	github.com/urfave/cli/v2 v2.0.0
+	golang.org/x/image v0.25.0
 	golang.org/x/net v0.38.0
+	google.golang.org/protobuf v1.36.9
 	gopkg.in/yaml.v3 v3.0.1
+	howett.net/plist v1.0.1
 )
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.0-20190314233015-f79a8a8ca69d // indirect
-	github.com/russross/blackfriday/v2 v2.0.1 // indirect
 	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
 )
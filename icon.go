@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"golang.org/x/image/draw"
+)
+
+// iconSize is the size of icon.png; icon@2x.png is twice as big.
+const iconSize = 32
+
+// writeIcons writes icon.png and icon@2x.png. Each comes from icon32x32 or
+// icon64x64, which may be PNG, JPEG, GIF, ICO or SVG files of any size.
+// Without icon64x64, icon@2x.png is made from icon32x32 if it is big enough.
+func writeIcons(dashing Dashing, writer fileWriter) {
+	if len(dashing.Icon32x32) > 0 {
+		if err := writeIcon(dashing.Icon32x32, iconSize, "icon.png", writer); err != nil {
+			fmt.Printf("Error writing icon: %s\n", err)
+		}
+	}
+	retina := dashing.Icon64x64
+	if len(retina) == 0 && len(dashing.Icon32x32) > 0 {
+		if size, err := iconSourceSize(dashing.Icon32x32); err == nil && size >= 2*iconSize {
+			retina = dashing.Icon32x32
+		} else if err == nil {
+			fmt.Printf("Warning: icon %s is too small for icon@2x.png; set icon64x64 for Retina displays\n", dashing.Icon32x32)
+		}
+	}
+	if len(retina) > 0 {
+		if err := writeIcon(retina, 2*iconSize, "icon@2x.png", writer); err != nil {
+			fmt.Printf("Error writing icon: %s\n", err)
+		}
+	}
+}
+
+// writeIcon writes src to dest in the docset as a size x size PNG. PNGs of
+// the right size are copied as is.
+func writeIcon(src string, size int, dest string, writer fileWriter) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(src), ".png") {
+		if c, err := png.DecodeConfig(bytes.NewReader(data)); err == nil && c.Width == size && c.Height == size {
+			fmt.Printf("Copying icon %s to %s\n", src, dest)
+			return writer.WriteFile(dest, data, 0644)
+		}
+	}
+
+	img, err := decodeIcon(src, data, size)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	if b := img.Bounds(); b.Dx() < size || b.Dy() < size {
+		fmt.Printf("Warning: icon %s is only %dx%d; %s will look blurry at %dx%d\n", src, b.Dx(), b.Dy(), dest, size, size)
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, scaleIcon(img, size)); err != nil {
+		return err
+	}
+	fmt.Printf("Writing %dx%d icon %s from %s\n", size, size, dest, src)
+	return writer.WriteFile(dest, out.Bytes(), 0644)
+}
+
+// iconSourceSize returns the largest dimension src can be rendered at
+// without upscaling. SVGs can be rendered at any size.
+func iconSourceSize(src string) (int, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return 0, err
+	}
+	if strings.EqualFold(filepath.Ext(src), ".svg") {
+		return int(^uint(0) >> 1), nil
+	}
+	// Ask for a size no ICO holds to get its biggest image.
+	img, err := decodeIcon(src, data, 1<<16)
+	if err != nil {
+		return 0, err
+	}
+	b := img.Bounds()
+	return min(b.Dx(), b.Dy()), nil
+}
+
+// decodeIcon decodes data read from src. SVGs are rendered at size; for
+// ICO files the smallest image at least size big is used.
+func decodeIcon(src string, data []byte, size int) (image.Image, error) {
+	switch strings.ToLower(filepath.Ext(src)) {
+	case ".svg":
+		return renderSVG(data, max(size, iconSize))
+	case ".ico":
+		return decodeICO(data, size)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// scaleIcon fits img into a transparent size x size square, keeping its
+// aspect ratio.
+func scaleIcon(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := size, size
+	if b.Dx() > b.Dy() {
+		h = max(1, b.Dy()*size/b.Dx())
+	} else if b.Dy() > b.Dx() {
+		w = max(1, b.Dx()*size/b.Dy())
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	at := image.Rect((size-w)/2, (size-h)/2, (size-w)/2+w, (size-h)/2+h)
+	draw.CatmullRom.Scale(dst, at, img, b, draw.Over, nil)
+	return dst
+}
+
+// renderSVG rasterizes an SVG image into a size x size image.
+func renderSVG(data []byte, size int) (image.Image, error) {
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.WarnErrorMode)
+	if err != nil {
+		return nil, err
+	}
+	icon.SetTarget(0, 0, float64(size), float64(size))
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	scanner := rasterx.NewScannerGV(size, size, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(size, size, scanner), 1)
+	return img, nil
+}
+
+// icoEntry is a directory entry of an ICO file.
+type icoEntry struct {
+	Width, Height, Colors, Reserved uint8
+	Planes, BitCount                uint16
+	Size, Offset                    uint32
+}
+
+// decodeICO decodes the image in an ICO file that best fits size: the
+// smallest one at least that big, or else the biggest one.
+func decodeICO(data []byte, size int) (image.Image, error) {
+	var header struct{ Reserved, Type, Count uint16 }
+	r := bytes.NewReader(data)
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil || header.Type != 1 {
+		return nil, fmt.Errorf("not an ICO file")
+	}
+	entries := make([]icoEntry, header.Count)
+	if err := binary.Read(r, binary.LittleEndian, entries); err != nil {
+		return nil, fmt.Errorf("truncated ICO file")
+	}
+
+	dim := func(e *icoEntry) int {
+		// A width of 0 means 256.
+		if e.Width == 0 {
+			return 256
+		}
+		return int(e.Width)
+	}
+	better := func(a, b *icoEntry) bool {
+		da, db := dim(a), dim(b)
+		switch {
+		case da == db:
+			return a.BitCount > b.BitCount
+		case (da >= size) != (db >= size):
+			return da >= size
+		case da >= size:
+			return da < db
+		}
+		return da > db
+	}
+	var best *icoEntry
+	for i := range entries {
+		if best == nil || better(&entries[i], best) {
+			best = &entries[i]
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("ICO file has no images")
+	}
+	end := uint64(best.Offset) + uint64(best.Size)
+	if end > uint64(len(data)) {
+		return nil, fmt.Errorf("truncated ICO file")
+	}
+	entry := data[best.Offset:end]
+	if bytes.HasPrefix(entry, []byte("\x89PNG")) {
+		return png.Decode(bytes.NewReader(entry))
+	}
+	return decodeDIB(entry)
+}
+
+// decodeDIB decodes the 24 or 32 bits per pixel bitmaps found in ICO files.
+// Their height covers the colors followed by a 1-bit transparency mask.
+func decodeDIB(data []byte) (image.Image, error) {
+	var h struct {
+		Size          uint32
+		Width, Height int32
+		Planes, Bits  uint16
+		Compression   uint32
+	}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &h); err != nil {
+		return nil, fmt.Errorf("truncated bitmap")
+	}
+	if h.Compression != 0 || (h.Bits != 24 && h.Bits != 32) {
+		return nil, fmt.Errorf("unsupported %d-bit bitmap in ICO file; use a PNG icon instead", h.Bits)
+	}
+	w, ht := int(h.Width), int(h.Height)/2
+	if w <= 0 || ht <= 0 {
+		return nil, fmt.Errorf("invalid bitmap size %dx%d", w, ht)
+	}
+	bpp := int(h.Bits) / 8
+	stride := (w*bpp + 3) &^ 3
+	maskStride := ((w + 31) / 32) * 4
+	if uint64(h.Size) > uint64(len(data)) {
+		return nil, fmt.Errorf("truncated bitmap")
+	}
+	pixels := data[h.Size:]
+	if len(pixels) < stride*ht {
+		return nil, fmt.Errorf("truncated bitmap")
+	}
+	mask := pixels[stride*ht:]
+	hasMask := len(mask) >= maskStride*ht
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, ht))
+	for y := 0; y < ht; y++ {
+		row := pixels[(ht-1-y)*stride:]
+		for x := 0; x < w; x++ {
+			p := row[x*bpp:]
+			a := uint8(0xff)
+			if bpp == 4 {
+				a = p[3]
+			} else if hasMask && mask[(ht-1-y)*maskStride+x/8]&(0x80>>(x%8)) != 0 {
+				a = 0
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: p[2], G: p[1], B: p[0], A: a})
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testPNG(t *testing.T, size int, c color.NRGBA) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// testDIB is a 2x2 24-bit bitmap, stored bottom-up, whose top left pixel is
+// masked out.
+func testDIB() []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, struct {
+		Size          uint32
+		Width, Height int32
+		Planes, Bits  uint16
+		Compression   uint32
+		Rest          [20]byte
+	}{Size: 40, Width: 2, Height: 4, Planes: 1, Bits: 24})
+	// Bottom row: blue, green. Top row: red, white. Rows are padded to 4 bytes.
+	b.Write([]byte{0xff, 0, 0, 0, 0xff, 0, 0, 0})
+	b.Write([]byte{0, 0, 0xff, 0xff, 0xff, 0xff, 0, 0})
+	// Mask rows, bottom up, padded to 4 bytes.
+	b.Write([]byte{0, 0, 0, 0})
+	b.Write([]byte{0x80, 0, 0, 0})
+	return b.Bytes()
+}
+
+// testICO packs images into an ICO file; sizes of 256 are written as 0.
+func testICO(sizes []int, images [][]byte) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, [3]uint16{0, 1, uint16(len(images))})
+	offset := 6 + 16*len(images)
+	for i, img := range images {
+		binary.Write(&b, binary.LittleEndian, icoEntry{
+			Width: uint8(sizes[i]), Height: uint8(sizes[i]), Planes: 1, BitCount: 32,
+			Size: uint32(len(img)), Offset: uint32(offset),
+		})
+		offset += len(img)
+	}
+	for _, img := range images {
+		b.Write(img)
+	}
+	return b.Bytes()
+}
+
+func TestDecodeICO(t *testing.T) {
+	red, blue := color.NRGBA{R: 0xff, A: 0xff}, color.NRGBA{B: 0xff, A: 0xff}
+	ico := testICO([]int{16, 64, 2}, [][]byte{testPNG(t, 16, red), testPNG(t, 64, blue), testDIB()})
+	for _, test := range []struct {
+		size, want int
+	}{
+		{16, 16},
+		{32, 64},
+		{64, 64},
+		// Nothing is big enough: the biggest wins.
+		{128, 64},
+		{2, 2},
+	} {
+		img, err := decodeICO(ico, test.size)
+		if err != nil {
+			t.Fatalf("decodeICO(%d): %s", test.size, err)
+		}
+		if got := img.Bounds().Dx(); got != test.want {
+			t.Errorf("decodeICO(%d) picked the %dx%d image, want %dx%d", test.size, got, got, test.want, test.want)
+		}
+	}
+
+	img, err := decodeICO(ico, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{0, 0, color.NRGBA{R: 0xff, A: 0}},
+		{1, 0, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+		{0, 1, color.NRGBA{B: 0xff, A: 0xff}},
+		{1, 1, color.NRGBA{G: 0xff, A: 0xff}},
+	} {
+		if got := color.NRGBAModel.Convert(img.At(test.x, test.y)); got != test.want {
+			t.Errorf("DIB pixel (%d, %d) = %v, want %v", test.x, test.y, got, test.want)
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"not an ICO": []byte("\x89PNG\r\n"),
+		"truncated":  ico[:6+16*3+10],
+		"no images":  testICO(nil, nil),
+	} {
+		if _, err := decodeICO(data, 32); err == nil {
+			t.Errorf("%s: decodeICO() succeeded", name)
+		}
+	}
+}
+
+func TestWriteIcons(t *testing.T) {
+	dir := t.TempDir()
+	blue := color.NRGBA{B: 0xff, A: 0xff}
+	writeFiles(t, dir, map[string]string{
+		"big.png":   string(testPNG(t, 128, blue)),
+		"small.png": string(testPNG(t, 16, blue)),
+		"icon.svg":  `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><rect width="10" height="10" fill="#00f"/></svg>`,
+	})
+	for _, test := range []struct {
+		name                 string
+		icon32, icon64       string
+		wantIcon, wantRetina int
+	}{
+		{"large PNG", "big.png", "", 32, 64},
+		{"small PNG", "small.png", "", 32, 0},
+		{"small PNG with icon64x64", "small.png", "big.png", 32, 64},
+		{"SVG", "icon.svg", "", 32, 64},
+	} {
+		out := t.TempDir()
+		d := Dashing{Icon32x32: filepath.Join(dir, test.icon32)}
+		if len(test.icon64) > 0 {
+			d.Icon64x64 = filepath.Join(dir, test.icon64)
+		}
+		writeIcons(d, fileWriter{out})
+		for file, want := range map[string]int{"icon.png": test.wantIcon, "icon@2x.png": test.wantRetina} {
+			b, err := os.ReadFile(filepath.Join(out, file))
+			if want == 0 {
+				if err == nil {
+					t.Errorf("%s: wrote %s", test.name, file)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %s", test.name, err)
+				continue
+			}
+			c, err := png.DecodeConfig(bytes.NewReader(b))
+			if err != nil || c.Width != want || c.Height != want {
+				t.Errorf("%s: %s is %dx%d (%v), want %dx%d", test.name, file, c.Width, c.Height, err, want, want)
+			}
+		}
+	}
+}
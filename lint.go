@@ -0,0 +1,344 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/net/html"
+)
+
+// flagIndex is the set of flags a Bazel version has, read from a docset's
+// Option entries or from flags exported with `build --export-flags`.
+type flagIndex struct {
+	source string
+	flags  map[string]flagInfo
+	// typed is true if the data says which flags are boolean. Docsets do if
+	// their command-line reference pages can be read; otherwise any --no form
+	// of a known flag is accepted.
+	typed bool
+	// docs is the docset's Documents directory, used to link to pages.
+	docs string
+	// commands lists the commands each flag is documented under, and inherits
+	// the commands whose options each command also takes.
+	commands map[string][]string
+	inherits map[string][]string
+}
+
+// dashMenuDescriptionRegexp finds the menu description in an index path.
+var dashMenuDescriptionRegexp = regexp.MustCompile(`<dash_entry_menuDescription=([^>]*)>`)
+
+// loadFlagIndex reads flags from a .docset directory or an exported JSON file.
+func loadFlagIndex(path string) (*flagIndex, error) {
+	x := &flagIndex{source: path, flags: map[string]flagInfo{}, commands: map[string][]string{}, inherits: map[string][]string{}}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var set flagSet
+		if err := json.Unmarshal(b, &set); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		x.typed = true
+		for _, f := range set.Flags {
+			x.add(f)
+		}
+		x.inherits = set.Inherits
+		return x, nil
+	}
+
+	x.docs = filepath.Join(path, "Contents/Resources/Documents")
+	x.typed = true
+	// The flags documented on each page, by their link.
+	pages := map[string]map[string]flagInfo{}
+	db, err := openIndex(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT name, path FROM searchIndex WHERE type = 'Option'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, page string
+		if err := rows.Scan(&name, &page); err != nil {
+			return nil, err
+		}
+		f := flagInfo{Page: dashPathRegexp.ReplaceAllString(page, "")}
+		f.Name, f.Boolean = flagName(name)
+		// The page says whether the flag is boolean or takes a value, and
+		// which command it belongs to.
+		file, _, _ := strings.Cut(f.Page, "#")
+		if _, ok := pages[file]; !ok {
+			pages[file] = x.readPage(file)
+		}
+		if documented, ok := pages[file][f.Page]; ok {
+			f = documented
+		} else {
+			x.typed = false
+		}
+		f.Deprecated, f.Description = false, ""
+		if m := dashMenuDescriptionRegexp.FindStringSubmatch(page); m != nil {
+			var labels []string
+			labels, f.Description = menuLabels(m[1])
+			for _, l := range labels {
+				f.Deprecated = f.Deprecated || l == "Deprecated"
+			}
+		}
+		x.add(f)
+	}
+	return x, rows.Err()
+}
+
+// readPage returns the flags documented on a docset page by their link and
+// adds the commands' inheritance to x. Pages that can't be read have none.
+func (x *flagIndex) readPage(file string) map[string]flagInfo {
+	flags := map[string]flagInfo{}
+	r, err := os.Open(filepath.Join(x.docs, file))
+	if err != nil {
+		return flags
+	}
+	defer r.Close()
+	top, err := html.Parse(r)
+	if err != nil {
+		return flags
+	}
+	for _, f := range extractFlags(top, file) {
+		flags[f.Page] = f
+	}
+	for command, from := range extractInherits(top) {
+		x.inherits[command] = from
+	}
+	return flags
+}
+
+// menuLabels splits a menu description into the status labels in front of it,
+// such as "[Deprecated]", and the rest.
+func menuLabels(description string) ([]string, string) {
+	labels := []string{}
+	for strings.HasPrefix(description, "[") {
+		label, rest, ok := strings.Cut(description[1:], "] ")
+		if !ok || len(label) == 0 || strings.ContainsAny(label, " []") {
+			break
+		}
+		labels = append(labels, label)
+		description = rest
+	}
+	return labels, description
+}
+
+func (x *flagIndex) add(f flagInfo) {
+	if _, ok := x.flags[f.Name]; !ok {
+		x.flags[f.Name] = f
+	}
+	if len(f.Command) > 0 && !slices.Contains(x.commands[f.Name], f.Command) {
+		x.commands[f.Name] = append(x.commands[f.Name], f.Command)
+	}
+	if f.Boolean {
+		no := "--no" + strings.TrimPrefix(f.Name, "--")
+		if _, ok := x.flags[no]; !ok {
+			x.flags[no] = f
+		}
+	}
+	if len(f.Abbreviation) > 0 {
+		if _, ok := x.flags[f.Abbreviation]; !ok {
+			x.flags[f.Abbreviation] = f
+		}
+	}
+}
+
+// lookup finds the flag an argument such as "--noflag" or "--flag=value" sets.
+func (x *flagIndex) lookup(arg string) (flagInfo, bool) {
+	name, _, _ := strings.Cut(arg, "=")
+	if f, ok := x.flags[name]; ok {
+		return f, true
+	}
+	if !x.typed && strings.HasPrefix(name, "--no") {
+		f, ok := x.flags["--"+strings.TrimPrefix(name, "--no")]
+		return f, ok
+	}
+	return flagInfo{}, false
+}
+
+// takesValue reports whether arg is a flag whose value may be the next
+// argument, as in "--copt -O2". Without typed data that is any flag that isn't
+// spelled "--[no]".
+func (x *flagIndex) takesValue(arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+	f, ok := x.lookup(arg)
+	return ok && !f.Boolean
+}
+
+// appliesTo reports whether a flag can be used with command, directly, through
+// the commands it inherits options from, or as a common option. Commands and
+// flags the data doesn't place are assumed to fit.
+func (x *flagIndex) appliesTo(f flagInfo, command string) bool {
+	documented := x.commands[f.Name]
+	if len(documented) == 0 || command == "common" || command == "always" || !x.knowsCommand(command) {
+		return true
+	}
+	if command != "startup" && slices.Contains(documented, "common") {
+		return true
+	}
+	seen := map[string]bool{}
+	for queue := []string{command}; len(queue) > 0; queue = queue[1:] {
+		c := queue[0]
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		if slices.Contains(documented, c) {
+			return true
+		}
+		queue = append(queue, x.inherits[c]...)
+	}
+	return false
+}
+
+func (x *flagIndex) knowsCommand(command string) bool {
+	if _, ok := x.inherits[command]; ok {
+		return true
+	}
+	for _, commands := range x.commands {
+		if slices.Contains(commands, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// link returns where a flag is documented.
+func (x *flagIndex) link(f flagInfo) string {
+	if len(x.docs) == 0 {
+		return f.Page
+	}
+	return filepath.Join(x.docs, f.Page)
+}
+
+// suggest returns the known flag closest to name, if any is close.
+func (x *flagIndex) suggest(name string) string {
+	best, bestDistance := "", 4
+	for known := range x.flags {
+		if d := levenshtein(name, known); d < bestDistance || (d == bestDistance && known < best) {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+func lintBazelrc(c *cli.Context) error {
+	source := c.String("docset")
+	if len(source) == 0 {
+		source = c.String("flags")
+	}
+	if len(source) == 0 {
+		return cli.Exit("lint-bazelrc needs --docset or --flags", 1)
+	}
+	flags, err := loadFlagIndex(source)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Failed to read flags: %s", err), 1)
+	}
+	var previous *flagIndex
+	if p := c.String("previous"); len(p) > 0 {
+		if previous, err = loadFlagIndex(p); err != nil {
+			return cli.Exit(fmt.Sprintf("Failed to read previous flags: %s", err), 1)
+		}
+	}
+
+	files := c.Args().Slice()
+	if len(files) == 0 {
+		files = []string{".bazelrc"}
+	}
+
+	problems := 0
+	for _, file := range files {
+		workspace := c.String("workspace")
+		if len(workspace) == 0 {
+			workspace = filepath.Dir(file)
+		}
+		args, err := parseBazelrc(file, workspace)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("Failed to read %s: %s", file, err), 1)
+		}
+		for _, p := range lintArgs(args, flags, previous) {
+			fmt.Println(p)
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		return cli.Exit(fmt.Sprintf("%d problems found", problems), 1)
+	}
+	return nil
+}
+
+// lintArgs lists the problems with the flags in args, one per line.
+func lintArgs(args []rcArg, flags, previous *flagIndex) []string {
+	problems := []string{}
+	valueAt := ""
+	for _, a := range args {
+		// The value of the previous flag, even if it starts with "-". Without
+		// typed data the previous flag may be boolean, so only "-O2"-like
+		// arguments are taken as its value.
+		if valueAt == a.position() && (flags.typed || !strings.HasPrefix(a.arg, "--")) {
+			valueAt = ""
+			continue
+		}
+		valueAt = ""
+		if !strings.HasPrefix(a.arg, "-") || a.arg == "--" {
+			// The value of the previous flag.
+			continue
+		}
+		if flags.takesValue(a.arg) {
+			valueAt = a.position()
+		}
+		if msg := lintFlag(a.arg, a.command, flags, previous); len(msg) > 0 {
+			command := a.command
+			if len(a.config) > 0 {
+				command += ":" + a.config
+			}
+			problems = append(problems, fmt.Sprintf("%s: %s: %s", a.position(), command, msg))
+		}
+	}
+	return problems
+}
+
+// lintFlag describes what is wrong with a flag argument of a command, or
+// returns "".
+func lintFlag(arg, command string, flags, previous *flagIndex) string {
+	name, _, _ := strings.Cut(arg, "=")
+	f, ok := flags.lookup(arg)
+	if !ok {
+		if previous != nil {
+			if old, ok := previous.lookup(arg); ok {
+				return fmt.Sprintf("removed flag %s (documented in %s at %s)", name, previous.source, previous.link(old))
+			}
+		}
+		if s := flags.suggest(name); len(s) > 0 {
+			return fmt.Sprintf("unknown flag %s (did you mean %s?)", name, s)
+		}
+		return fmt.Sprintf("unknown flag %s", name)
+	}
+	if f.Deprecated {
+		return fmt.Sprintf("deprecated flag %s: %s (%s)", name, f.Description, flags.link(f))
+	}
+	if !flags.appliesTo(f, command) {
+		return fmt.Sprintf("flag %s is for %s, not %s (%s)", name, strings.Join(flags.commands[f.Name], ", "), command, flags.link(f))
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMenuLabels(t *testing.T) {
+	for _, test := range []struct {
+		description string
+		labels      []string
+		rest        string
+	}{
+		{"[Experimental] [Deprecated] No-op.", []string{"Experimental", "Deprecated"}, "No-op."},
+		{"Specify the mode.", []string{}, "Specify the mode."},
+		{"[no space]", []string{}, "[no space]"},
+	} {
+		labels, rest := menuLabels(test.description)
+		if !reflect.DeepEqual(labels, test.labels) || rest != test.rest {
+			t.Errorf("menuLabels(%q) = %q, %q, want %q, %q", test.description, labels, rest, test.labels, test.rest)
+		}
+	}
+}
+
+func TestTakesValue(t *testing.T) {
+	x := &flagIndex{flags: map[string]flagInfo{}, typed: true}
+	x.add(flagInfo{Name: "--copt"})
+	x.add(flagInfo{Name: "--compilation_mode", Abbreviation: "-c"})
+	x.add(flagInfo{Name: "--keep_going", Boolean: true})
+	for arg, want := range map[string]bool{
+		"--copt":         true,
+		"--copt=-O2":     false,
+		"-c":             true,
+		"--keep_going":   false,
+		"--nokeep_going": false,
+		"--unknown":      false,
+	} {
+		if got := x.takesValue(arg); got != want {
+			t.Errorf("takesValue(%q) = %v, want %v", arg, got, want)
+		}
+	}
+
+}
+
+// lintPage is a command-line reference with startup, common, build and test
+// options.
+const lintPage = `<html><body>
+<h2><a name="startup_options">Options that appear before the command and are parsed by the client:</a></h2>
+<dl><dt id="flag--output_base"><code><a href="#flag--output_base">--output_base</a>=&lt;path&gt;</code></dt><dd><p>The output base.</p></dd></dl>
+<h2><a name="common_options">Options Common to all Commands</a></h2>
+<dl><dt id="flag--keep_going"><code><a href="#flag--keep_going">--[no]keep_going</a></code> [<code>-k</code>] default: "false"</dt><dd><p>Keep going.</p></dd></dl>
+<h2 id="build">Build Options</h2>
+<dl>
+<dt id="flag--copt"><code><a href="#flag--copt">--copt</a>=&lt;a string&gt;</code> multiple uses are accumulated</dt><dd><p>Options for gcc.</p></dd>
+<dt id="flag--legacy_whole_archive"><code><a href="#flag--legacy_whole_archive">--[no]legacy_whole_archive</a></code></dt><dd><p>Deprecated, no-op.</p></dd>
+</dl>
+<h2 id="test">Test Options</h2>
+<p>Inherits all options from build.</p>
+<dl><dt id="flag--test_output"><code><a href="#flag--test_output">--test_output</a>=&lt;summary, errors, all or streamed&gt;</code></dt><dd><p>Test output.</p></dd></dl>
+</body></html>`
+
+// writeDocset builds a docset under dir with the given pages and index
+// entries, each a name, type and path.
+func writeDocset(t *testing.T, dir string, pages map[string]string, entries [][3]string) string {
+	t.Helper()
+	docset := filepath.Join(dir, "bazel.docset")
+	if err := os.MkdirAll(filepath.Join(docset, "Contents/Resources/Documents"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, filepath.Join(docset, "Contents/Resources/Documents"), pages)
+	db, err := fileWriter{docset}.initDB("bazel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, e := range entries {
+		if _, err := db.Exec(`INSERT INTO searchIndex(name, type, path) VALUES (?,?,?)`, e[0], e[1], e[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return docset
+}
+
+// lintEntries are the Option entries config.yaml indexes from lintPage, with
+// --[no] flags expanded and -k as an alias.
+var lintEntries = [][3]string{
+	{"--output_base", "Option", "cmd.html#flag--output_base"},
+	{"--keep_going", "Option", "cmd.html#flag--keep_going"},
+	{"--nokeep_going", "Option", "cmd.html#flag--keep_going"},
+	{"-k", "Option", "cmd.html#flag--keep_going"},
+	{"--copt", "Option", "cmd.html#flag--copt"},
+	{"--legacy_whole_archive", "Option", "<dash_entry_menuDescription=[Deprecated] Deprecated, no-op.>cmd.html#flag--legacy_whole_archive"},
+	{"--nolegacy_whole_archive", "Option", "<dash_entry_menuDescription=[Deprecated] Deprecated, no-op.>cmd.html#flag--legacy_whole_archive"},
+	{"--test_output", "Option", "cmd.html#flag--test_output"},
+}
+
+func TestLintArgs(t *testing.T) {
+	dir := t.TempDir()
+	docset := writeDocset(t, dir, map[string]string{"cmd.html": lintPage}, lintEntries)
+	flags, err := loadFlagIndex(docset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !flags.typed {
+		t.Fatal("docset with a readable reference page isn't typed")
+	}
+
+	rc := func(command string, args ...string) []rcArg {
+		out := []rcArg{}
+		for _, a := range args {
+			out = append(out, rcArg{file: ".bazelrc", line: 1, command: command, arg: a})
+		}
+		return out
+	}
+	for _, test := range []struct {
+		name string
+		args []rcArg
+		want []string
+	}{
+		{"value after a flag", rc("build", "--copt", "-O2", "--keep_going"), nil},
+		{"boolean flag isn't followed by a value", rc("build", "--nokeep_going", "-O2"), []string{"unknown flag -O2"}},
+		{"abbreviation", rc("build", "-k"), nil},
+		{"deprecated", rc("build", "--nolegacy_whole_archive"), []string{"deprecated flag --nolegacy_whole_archive"}},
+		{"typo", rc("build", "--kep_going"), []string{"unknown flag --kep_going (did you mean --keep_going?)"}},
+		{"inherited", rc("test", "--copt=-O2", "--test_output=errors"), nil},
+		{"wrong command", rc("build", "--test_output=errors"), []string{"flag --test_output is for test, not build"}},
+		{"startup flag on a command", rc("build", "--output_base=/tmp"), []string{"flag --output_base is for startup, not build"}},
+		{"command flag at startup", rc("startup", "--keep_going"), []string{"flag --keep_going is for common, not startup"}},
+		{"common line", rc("common", "--test_output=errors"), nil},
+		{"undocumented command", rc("mobile-install", "--copt=-O2"), nil},
+	} {
+		got := lintArgs(test.args, flags, nil)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: lintArgs() = %q, want %q", test.name, got, test.want)
+			continue
+		}
+		for i, p := range got {
+			if !strings.Contains(p, test.want[i]) {
+				t.Errorf("%s: lintArgs() = %q, want %q", test.name, got, test.want)
+			}
+		}
+	}
+
+	// Without the page, flags after any flag not spelled --[no] are skipped
+	// unless they look like flags themselves.
+	docset = writeDocset(t, t.TempDir(), nil, lintEntries)
+	if flags, err = loadFlagIndex(docset); err != nil {
+		t.Fatal(err)
+	}
+	if flags.typed {
+		t.Fatal("docset without its reference page is typed")
+	}
+	if got := lintArgs(rc("build", "--copt", "-O2", "--kep_going"), flags, nil); len(got) != 1 || !strings.Contains(got[0], "unknown flag --kep_going") {
+		t.Errorf("untyped lintArgs() = %q", got)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	css "github.com/andybalholm/cascadia"
+	"github.com/russross/blackfriday/v2"
+	"golang.org/x/net/html"
+)
+
+var (
+	// devsiteFrontMatterRegexp matches the "Key: value" lines that start
+	// Markdown pages published with devsite, such as Bazel's own docs.
+	devsiteFrontMatterRegexp = regexp.MustCompile(`\A(?:[A-Za-z_]+: .*\n)+(?:\n|\z)`)
+	// devsiteTagRegexp matches devsite template tags such as
+	// {% include "_buttons.html" %} and {% dynamic setvar ... %}.
+	devsiteTagRegexp = regexp.MustCompile(`(?s)\{%.*?%\}`)
+)
+
+// defaultMarkdownTemplate wraps rendered Markdown when no template is configured.
+const defaultMarkdownTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<main>
+{{.Content}}
+</main>
+</body>
+</html>
+`
+
+// Markdown configures rendering .md sources into HTML pages. Rendered pages go
+// through the same selectors as scraped HTML.
+type Markdown struct {
+	Enabled bool `yaml:"enabled"`
+	// Template is an html/template file for each page. It can use .Title,
+	// .Content, .Path, .Version, .Name and .Package.
+	Template string `yaml:"template" expand:"file"`
+
+	tmpl *htmltemplate.Template
+}
+
+func (m *Markdown) loadTemplate() error {
+	var err error
+	if len(m.Template) == 0 {
+		m.tmpl, err = htmltemplate.New("markdown").Parse(defaultMarkdownTemplate)
+	} else {
+		m.tmpl, err = htmltemplate.ParseFiles(m.Template)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid markdown template: %w", err)
+	}
+	return nil
+}
+
+func markdownish(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// markdownOutputPath is the path of the page rendered from a Markdown file.
+func markdownOutputPath(src string) string {
+	return strings.TrimSuffix(src, filepath.Ext(src)) + ".html"
+}
+
+// parseMarkdown renders a Markdown file to HTML with the configured template
+// and parses the result like any other page.
+func parseMarkdown(src string, dashing Dashing) (parseResult, error) {
+	source, err := os.ReadFile(src)
+	if err != nil {
+		return parseResult{}, err
+	}
+	return parseMarkdownSource(source, src, dashing, dashing.pageRefs)
+}
+
+// parseMarkdownSource renders Markdown read from src and finds its entries with find.
+func parseMarkdownSource(source []byte, src string, dashing Dashing, find refFinder) (parseResult, error) {
+	out := markdownOutputPath(src)
+	if _, err := os.Stat(out); err == nil {
+		return parseResult{}, fmt.Errorf("the page rendered from it would overwrite %s", out)
+	}
+	page, err := renderMarkdown(source, src, dashing)
+	if err != nil {
+		return parseResult{}, err
+	}
+	top, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return parseResult{}, err
+	}
+	rewriteMarkdownLinks(top)
+	// Templates don't have to have the element css_selector_for_body picks
+	// out of scraped pages.
+	if dashing.CssSelectorForBody != nil && css.Selector(dashing.CssSelectorForBody.Sel.Match).MatchFirst(top) == nil {
+		dashing.CssSelectorForBody = nil
+	}
+	return parseHTMLNode(top, out, dashing, find)
+}
+
+// stripDevsite removes the front matter and template tags of Markdown written
+// for devsite, which would otherwise show up as text.
+func stripDevsite(source []byte) []byte {
+	if m := devsiteFrontMatterRegexp.Find(source); m != nil && (bytes.Contains(m, []byte("Project: ")) || bytes.Contains(m, []byte("Book: "))) {
+		source = source[len(m):]
+	}
+	return devsiteTagRegexp.ReplaceAll(source, nil)
+}
+
+func renderMarkdown(source []byte, src string, dashing Dashing) ([]byte, error) {
+	source = stripDevsite(source)
+	content := blackfriday.Run(source, blackfriday.WithExtensions(blackfriday.CommonExtensions|blackfriday.AutoHeadingIDs))
+
+	title := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	if doc, err := html.Parse(bytes.NewReader(content)); err == nil {
+		if h1 := css.MustCompile("h1").MatchFirst(doc); h1 != nil {
+			title = text(h1)
+		}
+	}
+
+	var b bytes.Buffer
+	err := dashing.Markdown.tmpl.Execute(&b, map[string]interface{}{
+		"Title":   title,
+		"Content": htmltemplate.HTML(content),
+		"Path":    src,
+		"Version": dashing.docsetVersion,
+		"Name":    dashing.Name,
+		"Package": dashing.Package,
+	})
+	return b.Bytes(), err
+}
+
+// rewriteMarkdownLinks points relative links at other Markdown files to the
+// pages rendered from them.
+func rewriteMarkdownLinks(top *html.Node) {
+	for _, node := range css.MustCompile("a[href]").MatchAll(top) {
+		for i, a := range node.Attr {
+			if a.Key != "href" {
+				continue
+			}
+			u, err := url.Parse(a.Val)
+			if err != nil || u.Scheme != "" || u.Host != "" || !markdownish(u.Path) {
+				continue
+			}
+			u.Path = markdownOutputPath(u.Path)
+			node.Attr[i].Val = u.String()
+		}
+	}
+}
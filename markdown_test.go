@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	css "github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+func TestStripDevsite(t *testing.T) {
+	for _, test := range []struct {
+		name, source, want string
+	}{
+		{
+			name:   "front matter",
+			source: "Project: /_project.yaml\nBook: /_book.yaml\n\n# Rules\n",
+			want:   "# Rules\n",
+		},
+		{
+			name:   "tags",
+			source: "# Rules\n{% include \"_buttons.html\" %}\nUse {% dynamic print variables.version %} now.\n",
+			want:   "# Rules\n\nUse  now.\n",
+		},
+		{
+			name:   "plain first paragraph",
+			source: "Note: this is not front matter.\n\n# Rules\n",
+			want:   "Note: this is not front matter.\n\n# Rules\n",
+		},
+	} {
+		if got := string(stripDevsite([]byte(test.source))); got != test.want {
+			t.Errorf("%s: stripDevsite() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMarkdownOutputPath(t *testing.T) {
+	for _, test := range []struct {
+		src, want string
+	}{
+		{"docs/rules.md", "docs/rules.html"},
+		{"README.markdown", "README.html"},
+	} {
+		if got := markdownOutputPath(test.src); got != test.want {
+			t.Errorf("markdownOutputPath(%q) = %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestRewriteMarkdownLinks(t *testing.T) {
+	top := parseTestHTML(t, `<a href="other.md#a">x</a><a href="https://example.com/x.md">y</a><a href="page.html">z</a>`)
+	rewriteMarkdownLinks(top)
+	var got []string
+	for _, n := range css.MustCompile("a").MatchAll(top) {
+		got = append(got, n.Attr[0].Val)
+	}
+	want := "other.html#a https://example.com/x.md page.html"
+	if strings.Join(got, " ") != want {
+		t.Errorf("hrefs = %v, want %s", got, want)
+	}
+}
+
+func TestParseMarkdownSource(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"taken.html": "<p>scraped</p>"})
+	dashing := Dashing{CssSelectorForBody: testSelector("devsite-content")}
+	if err := dashing.Markdown.loadTemplate(); err != nil {
+		t.Fatal(err)
+	}
+	source := []byte("Project: /_project.yaml\nBook: /_book.yaml\n\n# Rules\n{% include \"_buttons.html\" %}\n\nSee [flags](flags.md).\n")
+	find := func(*html.Node, string, *html.Node) []*reference { return nil }
+
+	res, err := parseMarkdownSource(source, filepath.Join(dir, "rules.md"), dashing, find)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := text(res.htmlNode)
+	for _, s := range []string{"Project:", "_book.yaml", "{%", "include"} {
+		if strings.Contains(body, s) {
+			t.Errorf("rendered page contains %q: %q", s, body)
+		}
+	}
+	if a := css.MustCompile("a").MatchFirst(res.htmlNode); a == nil || a.Attr[0].Val != "flags.html" {
+		t.Errorf("link not rewritten to flags.html")
+	}
+
+	if _, err := parseMarkdownSource(source, filepath.Join(dir, "taken.md"), dashing, find); err == nil {
+		t.Error("parseMarkdownSource() overwrote an existing page")
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "taken.html")); string(b) != "<p>scraped</p>" {
+		t.Errorf("taken.html = %q", b)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/net/html"
+)
+
+// docsetEntry is one entry of a built docset's index.
+type docsetEntry struct {
+	name, etype, page, description string
+	// labels are the status labels of its menu description.
+	labels []string
+}
+
+func (e docsetEntry) key() string {
+	return e.etype + "\x00" + e.name
+}
+
+// docsetEntries reads the entries of the given types from a docset, keyed by
+// type and name. The "--name" and "--noname" entries of a boolean flag are
+// folded into one "--[no]name" entry.
+func docsetEntries(docset string, types map[string]bool) (map[string]docsetEntry, error) {
+	db, err := openIndex(docset)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT s.name, s.type, s.path, COALESCE(d.description, '')
+		FROM searchIndex s LEFT JOIN entryDescription d ON d.id = s.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := map[string]docsetEntry{}
+	for rows.Next() {
+		var e docsetEntry
+		if err := rows.Scan(&e.name, &e.etype, &e.page, &e.description); err != nil {
+			return nil, err
+		}
+		if !types[e.etype] {
+			continue
+		}
+		if m := dashMenuDescriptionRegexp.FindStringSubmatch(e.page); m != nil {
+			e.labels, _ = menuLabels(m[1])
+		}
+		e.page = dashPathRegexp.ReplaceAllString(e.page, "")
+		if _, ok := entries[e.key()]; !ok {
+			entries[e.key()] = e
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for key, no := range entries {
+		if !strings.HasPrefix(no.name, "--no") {
+			continue
+		}
+		yes, ok := entries[no.etype+"\x00--"+strings.TrimPrefix(no.name, "--no")]
+		if !ok || yes.page != no.page {
+			continue
+		}
+		delete(entries, key)
+		delete(entries, yes.key())
+		yes.name = "--[no]" + strings.TrimPrefix(yes.name, "--")
+		entries[yes.key()] = yes
+	}
+	return entries, nil
+}
+
+// pageCache parses each page of a docset at most once and indexes its
+// elements by id.
+type pageCache struct {
+	docs  string
+	pages map[string]map[string]*html.Node
+	// labeled is true if the docset's entries have status labels, which are
+	// then trusted over the text of the pages.
+	labeled bool
+}
+
+func newPageCache(docset string, entries map[string]docsetEntry) *pageCache {
+	c := &pageCache{docs: filepath.Join(docset, "Contents/Resources/Documents"), pages: map[string]map[string]*html.Node{}}
+	for _, e := range entries {
+		c.labeled = c.labeled || len(e.labels) > 0
+	}
+	return c
+}
+
+// ids returns the elements of a page by id.
+func (c *pageCache) ids(page string) map[string]*html.Node {
+	if ids, ok := c.pages[page]; ok {
+		return ids
+	}
+	ids := map[string]*html.Node{}
+	if r, err := os.Open(filepath.Join(c.docs, page)); err == nil {
+		if top, err := html.Parse(r); err == nil {
+			for n := range top.Descendants() {
+				if id := attr(n, "id"); n.Type == html.ElementNode && len(id) > 0 {
+					if _, ok := ids[id]; !ok {
+						ids[id] = n
+					}
+				}
+			}
+		}
+		r.Close()
+	}
+	c.pages[page] = ids
+	return ids
+}
+
+// entryText returns the text of the element an entry points at and of the
+// element after it, which usually holds its documentation.
+func (c *pageCache) entryText(e docsetEntry) string {
+	page, fragment, _ := strings.Cut(e.page, "#")
+	if len(fragment) == 0 {
+		return e.description
+	}
+	if unescaped, err := url.PathUnescape(fragment); err == nil {
+		fragment = unescaped
+	}
+	n, ok := c.ids(page)[fragment]
+	if !ok {
+		return e.description
+	}
+	t := text(n)
+	if next := nextElementSibling(n); next != nil {
+		t += " " + text(next)
+	}
+	return e.description + " " + t
+}
+
+// deprecated reports whether an entry has the Deprecated status label, or for
+// docsets built without statuses, whether its documentation says so.
+func (c *pageCache) deprecated(e docsetEntry) bool {
+	if c.labeled {
+		return slices.Contains(e.labels, "Deprecated")
+	}
+	return flagDeprecatedRegexp.MatchString(c.entryText(e))
+}
+
+// migration is what changed between two versions of a docset.
+type migration struct {
+	from, to                   string
+	removed, added, deprecated []docsetEntry
+}
+
+func compareDocsets(fromDocset, toDocset string, types map[string]bool) (migration, error) {
+	m := migration{from: docsetLabel(fromDocset), to: docsetLabel(toDocset)}
+	from, err := docsetEntries(fromDocset, types)
+	if err != nil {
+		return m, fmt.Errorf("%s: %w", fromDocset, err)
+	}
+	to, err := docsetEntries(toDocset, types)
+	if err != nil {
+		return m, fmt.Errorf("%s: %w", toDocset, err)
+	}
+
+	fromPages, toPages := newPageCache(fromDocset, from), newPageCache(toDocset, to)
+	for key, e := range from {
+		newer, ok := to[key]
+		if !ok {
+			m.removed = append(m.removed, e)
+		} else if toPages.deprecated(newer) && !fromPages.deprecated(e) {
+			m.deprecated = append(m.deprecated, newer)
+		}
+	}
+	for key, e := range to {
+		if _, ok := from[key]; !ok {
+			m.added = append(m.added, e)
+		}
+	}
+	return m, nil
+}
+
+// docsetLabel names a docset by the directory it was built into, e.g. 8.0.0
+// for docset_versions/8.0.0/bazel.docset.
+func docsetLabel(docset string) string {
+	docset = filepath.Clean(docset)
+	if strings.HasSuffix(docset, ".docset") {
+		return filepath.Base(filepath.Dir(docset))
+	}
+	return filepath.Base(docset)
+}
+
+func (m migration) markdown() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Migration from %s to %s\n\n", m.from, m.to)
+	section := func(title string, entries []docsetEntry) {
+		fmt.Fprintf(&b, "## %s (%d)\n\n", title, len(entries))
+		if len(entries) == 0 {
+			b.WriteString("None.\n\n")
+			return
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].etype != entries[j].etype {
+				return entries[i].etype < entries[j].etype
+			}
+			return entries[i].name < entries[j].name
+		})
+		etype := ""
+		for _, e := range entries {
+			if e.etype != etype {
+				if len(etype) > 0 {
+					b.WriteString("\n")
+				}
+				etype = e.etype
+				fmt.Fprintf(&b, "### %s\n\n", etype)
+			}
+			fmt.Fprintf(&b, "- `%s`", e.name)
+			if len(e.description) > 0 {
+				fmt.Fprintf(&b, ": %s", escapeMarkdown(e.description))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	section("Removed", m.removed)
+	section("Newly deprecated", m.deprecated)
+	section("Added", m.added)
+	return b.Bytes()
+}
+
+// markdownEscapeChars are the characters Markdown gives a meaning to,
+// including the ones that start raw HTML.
+const markdownEscapeChars = "\\`*_{}[]()#+-.!|&<>~"
+
+// escapeMarkdown makes text render as itself in Markdown.
+func escapeMarkdown(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownEscapeChars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (m migration) html() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Migration from %s to %s</title>\n</head>\n<body>\n", html.EscapeString(m.from), html.EscapeString(m.to))
+	b.Write(blackfriday.Run(m.markdown()))
+	b.WriteString("</body>\n</html>\n")
+	return b.Bytes()
+}
+
+func migrationReport(c *cli.Context) error {
+	from, to := c.String("from"), c.String("to")
+	if len(from) == 0 || len(to) == 0 {
+		return cli.Exit("migration-report needs --from and --to docsets", 1)
+	}
+	types := map[string]bool{}
+	for _, t := range strings.Split(c.String("types"), ",") {
+		types[strings.TrimSpace(t)] = true
+	}
+
+	m, err := compareDocsets(from, to, types)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Failed to compare docsets: %s", err), 1)
+	}
+
+	var report []byte
+	switch c.String("format") {
+	case "markdown", "md":
+		report = m.markdown()
+	case "html":
+		report = m.html()
+	default:
+		return cli.Exit(fmt.Sprintf("unknown format '%s'", c.String("format")), 1)
+	}
+
+	if out := c.String("output"); len(out) > 0 {
+		fmt.Printf("%d removed, %d newly deprecated, %d added; writing %s\n", len(m.removed), len(m.deprecated), len(m.added), out)
+		return os.WriteFile(out, report, 0644)
+	}
+	_, err = os.Stdout.Write(report)
+	return err
+}
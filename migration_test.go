@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDocsetEntries(t *testing.T) {
+	docset := writeDocset(t, t.TempDir(), nil, [][3]string{
+		{"--keep_going", "Option", "cmd.html#flag--keep_going"},
+		{"--nokeep_going", "Option", "cmd.html#flag--keep_going"},
+		{"--noseparator", "Option", "cmd.html#flag--noseparator"},
+		{"--separator", "Option", "cmd.html#flag--separator"},
+		{"--jobs", "Option", "<dash_entry_menuDescription=[Deprecated] Use --local_resources.>cmd.html#flag--jobs"},
+		{"cc_library", "Class", "be/c-cpp.html#cc_library"},
+	})
+	entries, err := docsetEntries(docset, map[string]bool{"Option": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, e := range entries {
+		got = append(got, e.name+" "+strings.Join(e.labels, ","))
+	}
+	sort.Strings(got)
+	want := []string{"--[no]keep_going ", "--jobs Deprecated", "--noseparator ", "--separator "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("docsetEntries() = %q, want %q", got, want)
+	}
+}
+
+func TestCompareDocsets(t *testing.T) {
+	dir := t.TempDir()
+	page := `<dl><dt id="flag--jobs">--jobs</dt><dd><p>Deprecated, use --local_resources.</p></dd></dl>`
+	// Built without statuses: deprecation comes from the page text.
+	from := writeDocset(t, dir+"/7.6.0", map[string]string{"cmd.html": `<dl><dt id="flag--jobs">--jobs</dt><dd><p>Jobs.</p></dd></dl>`}, [][3]string{
+		{"--jobs", "Option", "cmd.html#flag--jobs"},
+		{"--keep_going", "Option", "cmd.html#flag--keep_going"},
+		{"--nokeep_going", "Option", "cmd.html#flag--keep_going"},
+		{"--old", "Option", "cmd.html#flag--old"},
+	})
+	to := writeDocset(t, dir+"/8.0.0", map[string]string{"cmd.html": page}, [][3]string{
+		{"--jobs", "Option", "cmd.html#flag--jobs"},
+		{"--keep_going", "Option", "cmd.html#flag--keep_going"},
+		{"--nokeep_going", "Option", "cmd.html#flag--keep_going"},
+		{"--new", "Option", "cmd.html#flag--new"},
+	})
+	types := map[string]bool{"Option": true}
+	names := func(entries []docsetEntry) string {
+		s := []string{}
+		for _, e := range entries {
+			s = append(s, e.name)
+		}
+		sort.Strings(s)
+		return strings.Join(s, " ")
+	}
+
+	m, err := compareDocsets(from, to, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.from != "7.6.0" || m.to != "8.0.0" {
+		t.Errorf("labels = %s, %s", m.from, m.to)
+	}
+	for _, test := range []struct {
+		section string
+		got     []docsetEntry
+		want    string
+	}{
+		{"removed", m.removed, "--old"},
+		{"added", m.added, "--new"},
+		{"deprecated", m.deprecated, "--jobs"},
+	} {
+		if got := names(test.got); got != test.want {
+			t.Errorf("%s = %q, want %q", test.section, got, test.want)
+		}
+	}
+
+	// With statuses, only the Deprecated label counts, whatever the page says.
+	to = writeDocset(t, dir+"/8.0.1", map[string]string{"cmd.html": page}, [][3]string{
+		{"--jobs", "Option", "cmd.html#flag--jobs"},
+		{"--keep_going", "Option", "<dash_entry_menuDescription=[Deprecated] No-op.>cmd.html#flag--keep_going"},
+		{"--nokeep_going", "Option", "<dash_entry_menuDescription=[Deprecated] No-op.>cmd.html#flag--keep_going"},
+	})
+	if m, err = compareDocsets(from, to, types); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(m.deprecated); got != "--[no]keep_going" {
+		t.Errorf("labeled deprecated = %q, want --[no]keep_going", got)
+	}
+}
+
+func TestMigrationReportEscapes(t *testing.T) {
+	m := migration{from: "7.6.0", to: "8.0.0", added: []docsetEntry{
+		{name: "--copt", etype: "Option", description: "Pass <script>alert(1)</script> to *gcc* & [friends]."},
+	}}
+	md := string(m.markdown())
+	if !strings.Contains(md, "- `--copt`: Pass \\<script\\>alert\\(1\\)\\</script\\> to \\*gcc\\* \\& \\[friends\\]\\.") {
+		t.Errorf("markdown() = %s", md)
+	}
+	page := string(m.html())
+	if strings.Contains(page, "<script>") || !strings.Contains(page, "Pass &lt;script&gt;alert(1)&lt;/script&gt; to *gcc* &amp; [friends].") {
+		t.Errorf("html() = %s", page)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	whitespaceRegexp = regexp.MustCompile(`\s+`)
+	optionalRegexp   = regexp.MustCompile(`\[([^\[\]]*)\]`)
+)
+
+// names turns the raw name of a matched element into the names that should
+// be added to the index, applying the selector's name transformation rules.
+func (t *Transform) names(raw string) []string {
+	name := raw
+	if t.NameRegex != nil {
+		name = t.NameRegex.ReplaceAllString(name, t.NameReplace)
+	}
+	if t.CollapseWhitespace {
+		name = whitespaceRegexp.ReplaceAllString(name, " ")
+	}
+	if len(t.TrimChars) > 0 {
+		name = strings.Trim(name, t.TrimChars)
+	}
+	name = strings.TrimSpace(name)
+
+	switch strings.ToLower(t.NameCase) {
+	case "lower":
+		name = strings.ToLower(name)
+	case "upper":
+		name = strings.ToUpper(name)
+	}
+
+	if t.ExpandOptional {
+		return expandOptional(name)
+	}
+	return []string{name}
+}
+
+// expandOptional expands every "[...]" segment of name into a variant with
+// and a variant without it, so "--[no]flag" becomes "--flag" and "--noflag".
+func expandOptional(name string) []string {
+	loc := optionalRegexp.FindStringSubmatchIndex(name)
+	if loc == nil {
+		return []string{name}
+	}
+	before, inner, after := name[:loc[0]], name[loc[2]:loc[3]], name[loc[1]:]
+
+	names := []string{}
+	for _, rest := range expandOptional(after) {
+		names = append(names, before+rest)
+	}
+	for _, rest := range expandOptional(after) {
+		names = append(names, before+inner+rest)
+	}
+	return names
+}
+
+// Alias adds extra index entries for a matched element. Every match of Regex
+// in the entry name (or in the text of the Closest ancestor) is expanded with
+// Template, which may refer to capture groups as $1 or ${name}.
+type Alias struct {
+	Regex    regexpYaml       `yaml:"regex"`
+	Template string           `yaml:"template" expand:"-"`
+	Closest  *cssSelectorYaml `yaml:"closest,omitempty"`
+}
+
+// aliases returns the alias names for node n, whose full entry name is name.
+func (t *Transform) aliases(n *html.Node, name string) []string {
+	aliases := []string{}
+	for _, a := range t.Aliases {
+		source := name
+		if a.Closest != nil {
+			ancestor := closest(n, a.Closest)
+			if ancestor == nil {
+				continue
+			}
+			source = text(ancestor)
+		}
+		for _, m := range a.Regex.FindAllStringSubmatchIndex(source, -1) {
+			alias := strings.TrimSpace(string(a.Regex.ExpandString(nil, a.Template, source, m)))
+			if len(alias) > 0 && alias != name {
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+	return aliases
+}
+
+// closest returns the nearest ancestor of n that matches sel.
+func closest(n *html.Node, sel *cssSelectorYaml) *html.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && sel.Match(p) {
+			return p
+		}
+	}
+	return nil
+}
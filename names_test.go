@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	css "github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+func testRegexp(s string) *regexpYaml {
+	return &regexpYaml{regexp.MustCompile(s)}
+}
+
+func testSelector(s string) *cssSelectorYaml {
+	sel, err := css.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return &cssSelectorYaml{Sel: sel, raw: s}
+}
+
+func parseTestHTML(t *testing.T, s string) *html.Node {
+	t.Helper()
+	top, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return top
+}
+
+func TestNames(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		t    Transform
+		raw  string
+		want []string
+	}{
+		{"unchanged", Transform{}, "  cc_library ", []string{"cc_library"}},
+		{"regex", Transform{NameRegex: testRegexp(`^(\w+)\(.*\)$`), NameReplace: "$1"}, "glob(include, exclude)", []string{"glob"}},
+		{"collapse whitespace", Transform{CollapseWhitespace: true}, "Build\n   Options", []string{"Build Options"}},
+		{"trim chars", Transform{TrimChars: "`:"}, "`name`:", []string{"name"}},
+		{"lower", Transform{NameCase: "lower"}, "CcInfo", []string{"ccinfo"}},
+		{"upper", Transform{NameCase: "Upper"}, "CcInfo", []string{"CCINFO"}},
+		{"expand optional", Transform{ExpandOptional: true}, "--[no]keep_going", []string{"--keep_going", "--nokeep_going"}},
+		{"rules in order", Transform{
+			NameRegex:          testRegexp(`\s*\[-\w\]`),
+			CollapseWhitespace: true,
+			ExpandOptional:     true,
+		}, "--[no]keep_going  [-k]", []string{"--keep_going", "--nokeep_going"}},
+	} {
+		if got := test.t.names(test.raw); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: names(%q) = %q, want %q", test.name, test.raw, got, test.want)
+		}
+	}
+}
+
+func TestExpandOptional(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want []string
+	}{
+		{"--keep_going", []string{"--keep_going"}},
+		{"--[no]keep_going", []string{"--keep_going", "--nokeep_going"}},
+		{"[a]b[c]", []string{"b", "bc", "ab", "abc"}},
+		{"--[no]a[b", []string{"--a[b", "--noa[b"}},
+	} {
+		if got := expandOptional(test.name); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expandOptional(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	top := parseTestHTML(t, `<dl><dt><code><a id="flag--compilation_mode">--compilation_mode</a>=&lt;fastbuild, dbg or opt&gt;</code> [<code>-c</code>] default: "fastbuild"</dt></dl>`)
+	n := css.MustCompile("dt a").MatchFirst(top)
+	for _, test := range []struct {
+		name    string
+		aliases []Alias
+		entry   string
+		want    []string
+	}{
+		{"none", nil, "--compilation_mode", []string{}},
+		{"closest", []Alias{{Regex: *testRegexp(`\[(-\w+)\]`), Template: "$1", Closest: testSelector("dt")}}, "--compilation_mode", []string{"-c"}},
+		{"no closest match", []Alias{{Regex: *testRegexp(`\[(-\w+)\]`), Template: "$1", Closest: testSelector("table")}}, "--compilation_mode", []string{}},
+		{"from the name", []Alias{{Regex: *testRegexp(`^--(\w+)$`), Template: "${1}"}}, "--compilation_mode", []string{"compilation_mode"}},
+		{"every match", []Alias{{Regex: *testRegexp(`_?([a-z]+)`), Template: "$1"}}, "compilation_mode", []string{"compilation", "mode"}},
+		{"not the name itself", []Alias{{Regex: *testRegexp(`.*`), Template: "$0"}}, "--compilation_mode", []string{}},
+	} {
+		sel := Transform{Aliases: test.aliases}
+		if got := sel.aliases(n, test.entry); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: aliases(%q) = %q, want %q", test.name, test.entry, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"howett.net/plist"
+)
+
+func readPlist(t *testing.T, docset string) map[string]interface{} {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(docset, "Contents/Info.plist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := map[string]interface{}{}
+	if _, err := plist.Unmarshal(b, &info); err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestAddPlist(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		config Dashing
+		want   map[string]interface{}
+	}{
+		{
+			name:   "minimal",
+			config: Dashing{Name: "Bazel", Index: "index.html"},
+			want: map[string]interface{}{
+				"CFBundleIdentifier":   "bazel",
+				"CFBundleName":         "Bazel",
+				"DocSetPlatformFamily": "bazel",
+				"isDashDocset":         true,
+				"DashDocSetFamily":     "dashtoc3",
+				"dashIndexFilePath":    "index.html",
+				"isJavaScriptEnabled":  false,
+			},
+		},
+		{
+			name:   "name from package",
+			config: Dashing{Index: "index.html"},
+			want: map[string]interface{}{
+				"CFBundleIdentifier":   "bazel",
+				"CFBundleName":         "Bazel",
+				"DocSetPlatformFamily": "bazel",
+				"isDashDocset":         true,
+				"DashDocSetFamily":     "dashtoc3",
+				"dashIndexFilePath":    "index.html",
+				"isJavaScriptEnabled":  false,
+			},
+		},
+		{
+			name: "all keys",
+			config: Dashing{
+				Name: "Bazel & <friends>", Index: "index.html", AllowJS: true,
+				ExternalURL: "https://bazel.build/", Keyword: "bazel", PluginKeyword: "bzl",
+				WebSearchKeyword: "bzl", PlayURL: "https://play.example.com/", DefaultFTSEnabled: true,
+				InfoPlist: map[string]interface{}{"DashDocSetFamily": "dashtoc", "DashDocSetIsJavaScriptEnabled": true},
+			},
+			want: map[string]interface{}{
+				"CFBundleIdentifier":            "bazel",
+				"CFBundleName":                  "Bazel & <friends>",
+				"DocSetPlatformFamily":          "bazel",
+				"isDashDocset":                  true,
+				"DashDocSetFamily":              "dashtoc",
+				"dashIndexFilePath":             "index.html",
+				"isJavaScriptEnabled":           true,
+				"DashDocSetFallbackURL":         "https://bazel.build/",
+				"DashDocSetKeyword":             "bazel",
+				"DashDocSetPluginKeyword":       "bzl",
+				"DashWebSearchKeyword":          "bzl",
+				"DashDocSetPlayURL":             "https://play.example.com/",
+				"DashDocSetDefaultFTSEnabled":   true,
+				"DashDocSetIsJavaScriptEnabled": true,
+			},
+		},
+	} {
+		docset := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(docset, "Contents"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		addPlist("bazel", &test.config, fileWriter{docset})
+		if got := readPlist(t, docset); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Info.plist =\n%v\nwant\n%v", test.name, got, test.want)
+		}
+	}
+}
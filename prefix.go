@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+
+	css "github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// PrefixSource is one element of a search prefix chain. Exactly one of Css,
+// PathRegex or Literal should be set.
+type PrefixSource struct {
+	// Css uses the text of the first element on the page matching this selector.
+	Css *cssSelectorYaml `yaml:"css,omitempty"`
+	// PathRegex uses the first capture group (or the whole match) of this
+	// regexp against the page's path.
+	PathRegex *regexpYaml `yaml:"path_regex,omitempty"`
+	// Literal uses this string as-is.
+	Literal string `yaml:"literal,omitempty"`
+	// Separator is placed between this source and what follows it. Defaults to ".".
+	Separator *string `yaml:"separator,omitempty"`
+}
+
+func (p PrefixSource) separator() string {
+	if p.Separator == nil {
+		return "."
+	}
+	return *p.Separator
+}
+
+func (p PrefixSource) value(top *html.Node, filepath string) string {
+	switch {
+	case p.Css != nil:
+		if node := css.Query(top, p.Css); node != nil {
+			return text(node)
+		}
+	case p.PathRegex != nil:
+		m := p.PathRegex.FindStringSubmatch(filepath)
+		if len(m) > 1 {
+			return m[1]
+		} else if len(m) == 1 {
+			return m[0]
+		}
+	default:
+		return p.Literal
+	}
+	return ""
+}
+
+// prefixSources returns the selector's prefix chain, including the older
+// css_selector_for_search_prefix option as its first element.
+func (t *Transform) prefixSources() []PrefixSource {
+	if t.CssSelectorForSearchPrefix == nil {
+		return t.SearchPrefix
+	}
+	return append([]PrefixSource{{Css: t.CssSelectorForSearchPrefix}}, t.SearchPrefix...)
+}
+
+// searchPrefix builds the prefix to put in front of name in the search index.
+// A source is left out if it is empty or if name already starts with it, which
+// saves us from having a prefix like "prefix.prefix.name".
+func (t *Transform) searchPrefix(top *html.Node, filepath, name string) string {
+	var b strings.Builder
+	for _, source := range t.prefixSources() {
+		v := source.value(top, filepath)
+		if len(v) == 0 || v == name || strings.HasPrefix(name, v+source.separator()) {
+			continue
+		}
+		b.WriteString(v)
+		b.WriteString(source.separator())
+	}
+	return b.String()
+}
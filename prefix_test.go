@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestSearchPrefix(t *testing.T) {
+	top := parseTestHTML(t, `<html><head><title>cc_common</title></head><body><h1 class="devsite-page-title">cc_common</h1><h2>compile</h2></body></html>`)
+	dash := "-"
+	empty := ""
+	for _, test := range []struct {
+		name  string
+		t     Transform
+		path  string
+		entry string
+		want  string
+	}{
+		{"none", Transform{}, "rules/lib/toplevel/cc_common.html", "compile", ""},
+		{"css", Transform{CssSelectorForSearchPrefix: testSelector(".devsite-page-title")}, "x.html", "compile", "cc_common."},
+		{"css without a match", Transform{CssSelectorForSearchPrefix: testSelector(".missing")}, "x.html", "compile", ""},
+		{"already prefixed", Transform{CssSelectorForSearchPrefix: testSelector(".devsite-page-title")}, "x.html", "cc_common.compile", ""},
+		{"same as the name", Transform{CssSelectorForSearchPrefix: testSelector("h1")}, "x.html", "cc_common", ""},
+		{"chain", Transform{
+			CssSelectorForSearchPrefix: testSelector("h1"),
+			SearchPrefix: []PrefixSource{
+				{PathRegex: testRegexp(`lib/(\w+)/`), Separator: &dash},
+				{Literal: "v8", Separator: &empty},
+			},
+		}, "rules/lib/toplevel/cc_common.html", "compile", "cc_common.toplevel-v8"},
+		{"path regex without a group", Transform{SearchPrefix: []PrefixSource{{PathRegex: testRegexp(`toplevel`)}}}, "rules/lib/toplevel/x.html", "compile", "toplevel."},
+		{"path regex without a match", Transform{SearchPrefix: []PrefixSource{{PathRegex: testRegexp(`providers`)}}}, "rules/lib/toplevel/x.html", "compile", ""},
+		{"custom separator already in the name", Transform{SearchPrefix: []PrefixSource{{Literal: "bazel", Separator: &dash}}}, "x.html", "bazel-bin", ""},
+	} {
+		if got := test.t.searchPrefix(top, test.path, test.entry); got != test.want {
+			t.Errorf("%s: searchPrefix(%q, %q) = %q, want %q", test.name, test.path, test.entry, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// dashPathRegexp strips the <dash_entry_*> metadata from an index path.
+var dashPathRegexp = regexp.MustCompile(`^(<dash_entry_[^>]*>)*`)
+
+// openIndex opens the search index of an existing docset.
+func openIndex(docset string) (*sql.DB, error) {
+	return sql.Open("sqlite3", "file:"+filepath.Join(docset, "Contents/Resources/docSet.dsidx")+"?mode=ro")
+}
+
+func query(c *cli.Context) error {
+	docset := strings.TrimSpace(c.String("docset"))
+	if len(docset) == 0 || c.NArg() == 0 {
+		return cli.Exit("usage: dashing query --docset DOCSET TERM", 1)
+	}
+	term := strings.Join(c.Args().Slice(), " ")
+
+	db, err := openIndex(docset)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Failed to open index: %s", err), 1)
+	}
+	defer db.Close()
+
+	if c.Bool("text") {
+		return queryText(db, term, c.Int("limit"))
+	}
+
+	rows, err := db.Query(`SELECT s.name, s.type, s.path, COALESCE(d.description, '')
+		FROM searchIndex s LEFT JOIN entryDescription d ON d.id = s.id
+		WHERE s.name LIKE ? ORDER BY length(s.name), s.name LIMIT ?`, "%"+term+"%", c.Int("limit"))
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Failed to query index: %s", err), 1)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, etype, path, description string
+		if err := rows.Scan(&name, &etype, &path, &description); err != nil {
+			return err
+		}
+		fmt.Printf("%-10s %s\t%s\n", etype, name, dashPathRegexp.ReplaceAllString(path, ""))
+		if len(description) > 0 {
+			fmt.Printf("           %s\n", description)
+		}
+	}
+	return rows.Err()
+}
+
+// queryText prints the pages whose text matches term, an SQLite full-text
+// query, with the matching words in brackets.
+func queryText(db *sql.DB, term string, limit int) error {
+	matches, err := searchPageText(db, term, limit)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Failed to query page text: %s", err), 1)
+	}
+	for _, m := range matches {
+		fmt.Printf("%s\t%s\n           %s\n", m.title, m.path, m.snippet)
+	}
+	return nil
+}
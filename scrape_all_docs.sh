@@ -28,13 +28,6 @@ function scrape_version {
         "+bazel.build/versions/$version/*" \
         "+bazel.build/*.css" \
         "-*?hl=*"
-
-    fix_links $outdir
-}
-
-function fix_links {
-    find $1 -type f -name '*.html' | xargs sed -i 's/-2.html/.html/'
-    find $1 -type f -name '*-2.html' -delete
 }
 
 function scrape_latest {
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a parsed version. Parts that are left out or written as "x" or
+// "*" are -1.
+type semver [3]int
+
+// parseSemver parses versions such as "7.4.0", "v8", "7.x" and "8.0.0rc2",
+// ignoring anything after the numbers.
+func parseSemver(s string) (semver, bool) {
+	v := semver{-1, -1, -1}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if len(s) == 0 {
+		return v, false
+	}
+	for i, part := range strings.SplitN(s, ".", 3) {
+		if part == "x" || part == "X" || part == "*" {
+			continue
+		}
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		if end == 0 || (i < 2 && end != len(part)) {
+			return v, false
+		}
+		v[i], _ = strconv.Atoi(part[:end])
+	}
+	return v, true
+}
+
+// compare returns -1, 0 or 1 as v is older than, the same as or newer than
+// w. Parts missing from either count as 0.
+func (v semver) compare(w semver) int {
+	for i := range v {
+		a, b := max(v[i], 0), max(w[i], 0)
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// within reports whether v matches the parts of pattern that are set.
+func (v semver) within(pattern semver) bool {
+	for i := range pattern {
+		if pattern[i] >= 0 && pattern[i] != v[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// versionMatches reports whether version is in the range given by
+// constraint: alternatives separated by "||", each a list of comparisons
+// such as ">=7.0.0 <7.4.0" that must all hold. A bare version matches every
+// version it is a prefix of, "~7.2.1" means 7.2.x from 7.2.1 on, "^7.2.1"
+// means 7.x from 7.2.1 on, and "*" matches anything, including versions
+// like "latest" that aren't numbers. Other non-numeric versions only match
+// themselves.
+func versionMatches(version, constraint string) (bool, error) {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "*" || constraint == version {
+		return true, nil
+	}
+	v, ok := parseSemver(version)
+	// Check every alternative so that a bad range is reported whatever the
+	// version.
+	matched := false
+	for _, alternative := range strings.Split(constraint, "||") {
+		all := true
+		comparisons := strings.Fields(strings.ReplaceAll(alternative, ",", " "))
+		if len(comparisons) == 0 {
+			return false, fmt.Errorf("empty version range in '%s'", constraint)
+		}
+		for _, c := range comparisons {
+			matches, err := compareVersion(v, c)
+			if err != nil {
+				return false, fmt.Errorf("invalid version range '%s': %w", constraint, err)
+			}
+			all = all && ok && matches
+		}
+		matched = matched || all
+	}
+	return matched, nil
+}
+
+// compareVersion reports whether v satisfies a single comparison such as
+// ">=7.0.0".
+func compareVersion(v semver, comparison string) (bool, error) {
+	op := comparison[:len(comparison)-len(strings.TrimLeft(comparison, "<>=!~^"))]
+	target, ok := parseSemver(comparison[len(op):])
+	if !ok {
+		return false, fmt.Errorf("'%s' is not a version", comparison)
+	}
+	switch op {
+	case "", "=", "==":
+		return v.within(target), nil
+	case "!=":
+		return !v.within(target), nil
+	case ">":
+		return v.compare(target) > 0, nil
+	case ">=":
+		return v.compare(target) >= 0, nil
+	case "<":
+		return v.compare(target) < 0, nil
+	case "<=":
+		return v.compare(target) <= 0, nil
+	case "~":
+		return v.compare(target) >= 0 && v.within(semver{target[0], target[1], -1}), nil
+	case "^":
+		return v.compare(target) >= 0 && v.within(semver{target[0], -1, -1}), nil
+	}
+	return false, fmt.Errorf("unknown comparison '%s' in '%s'", op, comparison)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"golang.org/x/net/html"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// serve previews a built docset in a browser: its pages, and a /search page
+// over the text of the pages for docsets built with --fts.
+func serve(c *cli.Context) error {
+	docset := strings.TrimSpace(c.String("docset"))
+	if len(docset) == 0 {
+		return cli.Exit("usage: dashing serve --docset DOCSET", 1)
+	}
+	db, err := openIndex(docset)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Failed to open index: %s", err), 1)
+	}
+	defer db.Close()
+
+	addr := c.String("addr")
+	fmt.Printf("Serving %s at http://%s/ (search at /search)\n", docset, addr)
+	return http.ListenAndServe(addr, previewHandler(docset, db))
+}
+
+func previewHandler(docset string, db *sql.DB) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(filepath.Join(docset, "Contents/Resources/Documents"))))
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		term := strings.TrimSpace(r.FormValue("q"))
+		var matches []pageMatch
+		if len(term) > 0 {
+			var err error
+			if matches, err = searchPageText(db, term, 50); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Search</title>\n</head>\n<body>\n")
+		fmt.Fprintf(w, "<form action=\"/search\"><input name=\"q\" value=\"%s\"> <input type=\"submit\" value=\"Search\"></form>\n", html.EscapeString(term))
+		if len(term) > 0 {
+			fmt.Fprintf(w, "<p>%d pages</p>\n<ul>\n", len(matches))
+			for _, m := range matches {
+				href := (&url.URL{Path: "/" + m.path}).String()
+				fmt.Fprintf(w, "<li><a href=\"%s\">%s</a><br>%s</li>\n", html.EscapeString(href), html.EscapeString(m.title), html.EscapeString(m.snippet))
+			}
+			fmt.Fprintf(w, "</ul>\n")
+		}
+		fmt.Fprintf(w, "</body>\n</html>\n")
+	})
+	return mux
+}
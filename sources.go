@@ -0,0 +1,96 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+)
+
+// Source is one tree of documentation in a docset that merges several, such
+// as the Bazel site, rules_go's Stardoc output and a folder of Markdown.
+// Fields that are not set fall back to the top-level configuration.
+type Source struct {
+	// Name identifies the source in the build output.
+	Name string `yaml:"name"`
+	// WalkRoot is the directory to start walking from.
+	WalkRoot string `yaml:"walk_root"`
+	// URLPrefix is the directory inside Documents the source is written to.
+	URLPrefix string `yaml:"url_prefix"`
+	// SearchPrefix is put in front of every entry name from this source.
+	SearchPrefix      string             `yaml:"search_prefix"`
+	Selectors         []Transform        `yaml:"selectors"`
+	BackupSelectors   []Transform        `yaml:"backup_selectors"`
+	IgnorePathRegexes []*regexp.Regexp   `yaml:"ignore_path_regexes"`
+	RemoveElements    []*cssSelectorYaml `yaml:"remove_elements"`
+	// CssSelectorForBody and CssSelectorForTitle replace the top-level ones;
+	// sources that aren't from the Bazel site rarely share its layout.
+	CssSelectorForBody  *cssSelectorYaml `yaml:"css_selector_for_body"`
+	CssSelectorForTitle *cssSelectorYaml `yaml:"css_selector_for_title"`
+	Markdown            *Markdown        `yaml:"markdown"`
+	Stardoc             *Stardoc         `yaml:"stardoc"`
+}
+
+// sources returns a configuration for each source to build. Without any
+// sources, the top-level configuration is built on its own.
+func (d Dashing) sources() []Dashing {
+	if len(d.Sources) == 0 {
+		return []Dashing{d}
+	}
+	configs := []Dashing{}
+	for i := range d.Sources {
+		configs = append(configs, d.forSource(&d.Sources[i]))
+	}
+	return configs
+}
+
+// forSource returns the configuration for building s.
+func (d Dashing) forSource(s *Source) Dashing {
+	d.WalkRoot = s.WalkRoot
+	if s.Selectors != nil {
+		d.Selectors = s.Selectors
+	}
+	if s.BackupSelectors != nil {
+		d.BackupSelectors = s.BackupSelectors
+	}
+	if s.IgnorePathRegexes != nil {
+		d.IgnorePathRegexes = s.IgnorePathRegexes
+	}
+	if s.RemoveElements != nil {
+		d.RemoveElements = s.RemoveElements
+	}
+	if s.CssSelectorForBody != nil {
+		d.CssSelectorForBody = s.CssSelectorForBody
+	}
+	if s.CssSelectorForTitle != nil {
+		d.CssSelectorForTitle = s.CssSelectorForTitle
+	}
+	if s.Markdown != nil {
+		d.Markdown = *s.Markdown
+	}
+	if s.Stardoc != nil {
+		d.Stardoc = *s.Stardoc
+	}
+	d.source = s
+	d.duplicates = nil
+	return d
+}
+
+// documentPath is where the file at src is written inside Documents.
+func (d *Dashing) documentPath(src string) string {
+	if d.source == nil {
+		return src
+	}
+	rel, err := filepath.Rel(d.source.WalkRoot, src)
+	if err != nil {
+		return src
+	}
+	return path.Join(d.source.URLPrefix, filepath.ToSlash(rel))
+}
+
+// searchName is the name ref is indexed under.
+func (d *Dashing) searchName(ref *reference) string {
+	if d.source == nil {
+		return ref.name
+	}
+	return d.source.SearchPrefix + ref.name
+}
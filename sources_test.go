@@ -0,0 +1,59 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSources(t *testing.T) {
+	top := Dashing{
+		WalkRoot:           "bazel",
+		Selectors:          []Transform{{CssSelector: *testSelector("h2"), Type: "Section"}},
+		CssSelectorForBody: testSelector("devsite-content"),
+		IgnorePathRegexes:  []*regexp.Regexp{regexp.MustCompile(`-2\.html$`)},
+	}
+	if got := top.sources(); len(got) != 1 || got[0].WalkRoot != "bazel" || got[0].source != nil {
+		t.Errorf("sources() without sources = %+v, want the top-level configuration", got)
+	}
+
+	markdown := Markdown{Enabled: true}
+	top.Sources = []Source{
+		{Name: "bazel", WalkRoot: "bazel"},
+		{
+			Name: "rules_go", WalkRoot: "rules_go/docs", URLPrefix: "rules_go", SearchPrefix: "go: ",
+			Selectors:          []Transform{{CssSelector: *testSelector("h3"), Type: "Function"}},
+			CssSelectorForBody: testSelector("main"),
+			Markdown:           &markdown,
+		},
+	}
+	configs := top.sources()
+	if len(configs) != 2 {
+		t.Fatalf("sources() = %d configurations, want 2", len(configs))
+	}
+	bazel, rulesGo := configs[0], configs[1]
+	if bazel.Selectors[0].Type != "Section" || bazel.CssSelectorForBody.String() != "devsite-content" || bazel.Markdown.Enabled {
+		t.Errorf("bazel source doesn't inherit the top-level configuration: %+v", bazel)
+	}
+	if rulesGo.WalkRoot != "rules_go/docs" || rulesGo.Selectors[0].Type != "Function" || rulesGo.CssSelectorForBody.String() != "main" || !rulesGo.Markdown.Enabled {
+		t.Errorf("rules_go source doesn't override the top-level configuration: %+v", rulesGo)
+	}
+	if len(rulesGo.IgnorePathRegexes) != 1 {
+		t.Errorf("rules_go source lost the top-level ignore_path_regexes")
+	}
+
+	for _, test := range []struct {
+		d                *Dashing
+		src, path, entry string
+	}{
+		{&top, "bazel/docs/a.html", "bazel/docs/a.html", "go_library"},
+		{&bazel, "bazel/docs/a.html", "docs/a.html", "go_library"},
+		{&rulesGo, "rules_go/docs/rules.md", "rules_go/rules.md", "go: go_library"},
+	} {
+		if got := test.d.documentPath(test.src); got != test.path {
+			t.Errorf("documentPath(%q) = %q, want %q", test.src, got, test.path)
+		}
+		if got := test.d.searchName(&reference{name: "go_library"}); got != test.entry {
+			t.Errorf("%s: searchName() = %q, want %q", test.src, got, test.entry)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	css "github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Stardoc configures reading Stardoc output for rules_* repositories. Entries
+// come straight from the structure of the output instead of from selectors.
+type Stardoc struct {
+	Enabled bool `yaml:"enabled"`
+	// MatchPath selects the files that are Stardoc output. Files ending in
+	// .md are read as Stardoc Markdown, .json as a ModuleInfo in the proto3
+	// JSON mapping, and anything else as a binary ModuleInfo. Defaults to
+	// files ending in .binaryproto or .pb.
+	MatchPath *regexpYaml `yaml:"matchpath"`
+	// Types overrides the entry type of each kind of symbol: rule,
+	// repository_rule, aspect, macro, provider, function, attribute, field
+	// and parameter. Types may use type_aliases.
+	Types map[string]string `yaml:"types"`
+}
+
+// defaultStardocTypes are the entry types used without stardoc.types. Dash
+// has no rule type, so rules are classes like on the Build Encyclopedia.
+var defaultStardocTypes = map[string]string{
+	"rule":            "Class",
+	"repository_rule": "Class",
+	"aspect":          "Class",
+	"macro":           "Macro",
+	"provider":        "Provider",
+	"function":        "Function",
+	"attribute":       "Attribute",
+	"field":           "Field",
+	"parameter":       "Parameter",
+}
+
+// stardocMemberKinds is the kind of the members listed under each kind of symbol.
+var stardocMemberKinds = map[string]string{
+	"rule":            "attribute",
+	"repository_rule": "attribute",
+	"aspect":          "attribute",
+	"macro":           "attribute",
+	"provider":        "field",
+	"function":        "parameter",
+}
+
+// stardocSectionKinds guesses a symbol's kind from the heading of its member table.
+var stardocSectionKinds = map[string]string{
+	"ATTRIBUTES": "rule",
+	"FIELDS":     "provider",
+	"PARAMETERS": "function",
+}
+
+func (s Stardoc) matches(src string) bool {
+	if !s.Enabled {
+		return false
+	}
+	if s.MatchPath != nil {
+		return s.MatchPath.MatchString(src)
+	}
+	switch strings.ToLower(filepath.Ext(src)) {
+	case ".binaryproto", ".pb":
+		return true
+	}
+	return false
+}
+
+// resolveTypes fills in the default types, applies type_aliases and checks
+// that every type is one Dash supports.
+func (s *Stardoc) resolveTypes(d *Dashing) error {
+	if !s.Enabled {
+		return nil
+	}
+	types := map[string]string{}
+	for kind, t := range defaultStardocTypes {
+		types[kind] = t
+	}
+	for kind, t := range s.Types {
+		if _, ok := defaultStardocTypes[kind]; !ok {
+			return fmt.Errorf("stardoc.types: unknown symbol kind '%s'", kind)
+		}
+		types[kind] = t
+	}
+
+	problems := []string{}
+	for kind, t := range types {
+		types[kind] = d.resolveType(t)
+		if !isDashEntryType(types[kind]) {
+			problems = append(problems, fmt.Sprintf("stardoc.types.%s: %s", kind, unknownType(types[kind])))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid entry types:\n  %s", strings.Join(problems, "\n  "))
+	}
+	s.Types = types
+	return nil
+}
+
+// parseStardoc reads Stardoc output and renders it into a page whose entries
+// come from the documented symbols.
+func parseStardoc(src string, dashing Dashing) (parseResult, error) {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return parseResult{}, err
+	}
+
+	source := b
+	if !markdownish(src) {
+		var module stardocModule
+		if strings.EqualFold(filepath.Ext(src), ".json") {
+			module, err = decodeStardocJSON(b)
+		} else {
+			module, err = decodeStardocProto(b)
+		}
+		if err != nil {
+			return parseResult{}, err
+		}
+		source = module.markdown(src)
+	}
+	return parseMarkdownSource(source, src, dashing, dashing.Stardoc.refs(dashing))
+}
+
+// markdown renders the module the way Stardoc's default templates do. Each
+// symbol's anchor records its kind so that it doesn't need to be guessed.
+func (m stardocModule) markdown(src string) []byte {
+	var b bytes.Buffer
+	title := m.File
+	if len(title) == 0 {
+		title = strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	if len(m.ModuleDocstring) > 0 {
+		fmt.Fprintf(&b, "%s\n\n", m.ModuleDocstring)
+	}
+
+	symbol := func(kind, name, doc string) {
+		fmt.Fprintf(&b, "<a id=\"%s\" data-stardoc-kind=\"%s\"></a>\n\n## %s\n\n", name, kind, name)
+		if len(doc) > 0 {
+			fmt.Fprintf(&b, "%s\n\n", doc)
+		}
+	}
+	attributes := func(name string, attrs []stardocAttribute) {
+		if len(attrs) == 0 {
+			return
+		}
+		b.WriteString("**ATTRIBUTES**\n\n| Name | Description | Type | Mandatory | Default |\n| :--- | :--- | :--- | :--- | :--- |\n")
+		for _, a := range attrs {
+			mandatory := "optional"
+			if a.Mandatory {
+				mandatory = "required"
+			}
+			fmt.Fprintf(&b, "| <a id=\"%s-%s\"></a>%s | %s | %s | %s | %s |\n", name, a.Name, a.Name, tableCell(a.DocString), strings.ToLower(a.Type), mandatory, codeCell(a.DefaultValue))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, r := range m.RuleInfo {
+		symbol("rule", r.RuleName, r.DocString)
+		attributes(r.RuleName, r.Attribute)
+	}
+	for _, r := range m.RepositoryRuleInfo {
+		symbol("repository_rule", r.RuleName, r.DocString)
+		attributes(r.RuleName, r.Attribute)
+	}
+	for _, mac := range m.MacroInfo {
+		symbol("macro", mac.MacroName, mac.DocString)
+		attributes(mac.MacroName, mac.Attribute)
+	}
+	for _, a := range m.AspectInfo {
+		symbol("aspect", a.AspectName, a.DocString)
+		attributes(a.AspectName, a.Attribute)
+	}
+	for _, p := range m.ProviderInfo {
+		symbol("provider", p.ProviderName, p.DocString)
+		if len(p.FieldInfo) > 0 {
+			b.WriteString("**FIELDS**\n\n| Name | Description |\n| :--- | :--- |\n")
+			for _, f := range p.FieldInfo {
+				fmt.Fprintf(&b, "| <a id=\"%s-%s\"></a>%s | %s |\n", p.ProviderName, f.Name, f.Name, tableCell(f.DocString))
+			}
+			b.WriteString("\n")
+		}
+	}
+	for _, f := range m.FuncInfo {
+		symbol("function", f.FunctionName, f.DocString)
+		if len(f.Parameter) > 0 {
+			b.WriteString("**PARAMETERS**\n\n| Name | Description | Default Value |\n| :--- | :--- | :--- |\n")
+			for _, p := range f.Parameter {
+				fmt.Fprintf(&b, "| <a id=\"%s-%s\"></a>%s | %s | %s |\n", f.FunctionName, p.Name, p.Name, tableCell(p.DocString), codeCell(p.DefaultValue))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.Bytes()
+}
+
+func tableCell(s string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(s), " "), "|", `\|`)
+}
+
+func codeCell(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return "<code>" + html.EscapeString(tableCell(s)) + "</code>"
+}
+
+// refs returns a refFinder for pages rendered from Stardoc output. Each h2 is
+// a symbol, and anchors named "<symbol>-<member>" are its members.
+func (s Stardoc) refs(dashing Dashing) refFinder {
+	return func(top *html.Node, filepath string, headNode *html.Node) []*reference {
+		refs := []*reference{}
+		pageTitle := dashing.pageTitle(top)
+
+		add := func(n *html.Node, name, kind, id, description string, tocLevel int) {
+			etype := s.Types[kind]
+			refs = append(refs, &reference{
+				selector:    "stardoc:" + kind,
+				source:      "stardoc",
+				name:        name,
+				etype:       etype,
+				href:        filepath + "#" + id,
+				pageTitle:   pageTitle,
+				description: truncate(description, maxDescriptionLength),
+			})
+			tocAnchor, linkNode := tocAnchorAndLinkNode(name, etype, tocLevel)
+			headNode.AppendChild(linkNode)
+			n.Parent.InsertBefore(tocAnchor, n)
+		}
+
+		for _, h2 := range css.MustCompile("h2").MatchAll(top) {
+			name := text(h2)
+			section := stardocSection(h2)
+
+			id, kind := attr(h2, "id"), ""
+			if anchor := stardocAnchor(h2); anchor != nil {
+				id, kind = anchorID(anchor), attr(anchor, "data-stardoc-kind")
+			}
+			if len(kind) == 0 {
+				kind = "function"
+				for _, n := range section {
+					for _, strong := range css.MustCompile("strong").MatchAll(n) {
+						if k, ok := stardocSectionKinds[text(strong)]; ok {
+							kind = k
+						}
+					}
+				}
+			}
+			add(h2, name, kind, id, stardocDescription(section), 1)
+
+			for _, n := range section {
+				for _, a := range css.MustCompile("a[id], a[name]").MatchAll(n) {
+					memberID := anchorID(a)
+					member, ok := strings.CutPrefix(memberID, name+"-")
+					if !ok || len(member) == 0 {
+						continue
+					}
+					description := ""
+					if td := closestElement(a, atom.Td); td != nil {
+						for next := td.NextSibling; next != nil; next = next.NextSibling {
+							if next.DataAtom == atom.Td {
+								description = text(next)
+								break
+							}
+						}
+					}
+					add(a, name+"."+member, stardocMemberKinds[kind], memberID, description, 0)
+				}
+			}
+		}
+		return refs
+	}
+}
+
+// stardocSection returns the siblings after h2 up to the next h2.
+func stardocSection(h2 *html.Node) []*html.Node {
+	section := []*html.Node{}
+	for n := h2.NextSibling; n != nil && n.DataAtom != atom.H2; n = n.NextSibling {
+		if n.Type == html.ElementNode {
+			section = append(section, n)
+		}
+	}
+	return section
+}
+
+// stardocAnchor returns the <a id="..."> that Stardoc puts before a symbol's heading.
+func stardocAnchor(h2 *html.Node) *html.Node {
+	prev := h2.PrevSibling
+	for prev != nil && prev.Type != html.ElementNode {
+		prev = prev.PrevSibling
+	}
+	if prev == nil {
+		return nil
+	}
+	if prev.DataAtom == atom.A && len(anchorID(prev)) > 0 {
+		return prev
+	}
+	if prev.DataAtom == atom.P && len(text(prev)) == 0 {
+		if a := css.MustCompile("a[id], a[name]").MatchFirst(prev); a != nil {
+			return a
+		}
+	}
+	return nil
+}
+
+// anchorID returns the target of an anchor. Older Stardoc versions wrote
+// <a name="#symbol"> instead of <a id="symbol">.
+func anchorID(a *html.Node) string {
+	if id := attr(a, "id"); len(id) > 0 {
+		return id
+	}
+	return strings.TrimPrefix(attr(a, "name"), "#")
+}
+
+// stardocDescription returns the first paragraph of a symbol's documentation.
+func stardocDescription(section []*html.Node) string {
+	for _, n := range section {
+		if n.DataAtom != atom.P {
+			continue
+		}
+		t := whitespaceRegexp.ReplaceAllString(text(n), " ")
+		if _, ok := stardocSectionKinds[t]; ok {
+			return ""
+		}
+		if len(t) > 0 {
+			return t
+		}
+	}
+	return ""
+}
+
+func closestElement(n *html.Node, a atom.Atom) *html.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.DataAtom == a {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The types below mirror the parts of Stardoc's ModuleInfo proto
+// (stardoc_output.proto) that end up in the index. JSON field names follow the
+// proto3 JSON mapping.
+
+type stardocModule struct {
+	File               string            `json:"file"`
+	ModuleDocstring    string            `json:"moduleDocstring"`
+	RuleInfo           []stardocRule     `json:"ruleInfo"`
+	ProviderInfo       []stardocProvider `json:"providerInfo"`
+	FuncInfo           []stardocFunction `json:"funcInfo"`
+	AspectInfo         []stardocAspect   `json:"aspectInfo"`
+	RepositoryRuleInfo []stardocRule     `json:"repositoryRuleInfo"`
+	MacroInfo          []stardocMacro    `json:"macroInfo"`
+}
+
+type stardocRule struct {
+	RuleName  string             `json:"ruleName"`
+	DocString string             `json:"docString"`
+	Attribute []stardocAttribute `json:"attribute"`
+}
+
+type stardocMacro struct {
+	MacroName string             `json:"macroName"`
+	DocString string             `json:"docString"`
+	Attribute []stardocAttribute `json:"attribute"`
+}
+
+type stardocAspect struct {
+	AspectName string             `json:"aspectName"`
+	DocString  string             `json:"docString"`
+	Attribute  []stardocAttribute `json:"attribute"`
+}
+
+type stardocAttribute struct {
+	Name         string `json:"name"`
+	DocString    string `json:"docString"`
+	Type         string `json:"type"`
+	Mandatory    bool   `json:"mandatory"`
+	DefaultValue string `json:"defaultValue"`
+}
+
+type stardocProvider struct {
+	ProviderName string                 `json:"providerName"`
+	DocString    string                 `json:"docString"`
+	FieldInfo    []stardocProviderField `json:"fieldInfo"`
+}
+
+type stardocProviderField struct {
+	Name      string `json:"name"`
+	DocString string `json:"docString"`
+}
+
+type stardocFunction struct {
+	FunctionName string             `json:"functionName"`
+	DocString    string             `json:"docString"`
+	Parameter    []stardocParameter `json:"parameter"`
+}
+
+type stardocParameter struct {
+	Name         string `json:"name"`
+	DocString    string `json:"docString"`
+	DefaultValue string `json:"defaultValue"`
+	Mandatory    bool   `json:"mandatory"`
+}
+
+// stardocAttributeTypes are the names of Stardoc's AttributeType enum values.
+var stardocAttributeTypes = []string{
+	"UNKNOWN", "NAME", "INT", "LABEL", "STRING", "STRING_LIST", "INT_LIST",
+	"LABEL_LIST", "BOOLEAN", "LABEL_STRING_DICT", "STRING_DICT",
+	"STRING_LIST_DICT", "OUTPUT", "OUTPUT_LIST", "LABEL_DICT_UNARY",
+	"LABEL_LIST_DICT",
+}
+
+func decodeStardocJSON(b []byte) (stardocModule, error) {
+	var m stardocModule
+	err := json.Unmarshal(b, &m)
+	return m, err
+}
+
+// protoFields holds the fields of one encoded message by field number.
+type protoFields struct {
+	bytes   map[protowire.Number][][]byte
+	varints map[protowire.Number][]uint64
+}
+
+func decodeProtoFields(b []byte) (protoFields, error) {
+	f := protoFields{bytes: map[protowire.Number][][]byte{}, varints: map[protowire.Number][]uint64{}}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return f, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return f, protowire.ParseError(n)
+			}
+			f.bytes[num] = append(f.bytes[num], v)
+			b = b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return f, protowire.ParseError(n)
+			}
+			f.varints[num] = append(f.varints[num], v)
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return f, protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return f, nil
+}
+
+func (f protoFields) string(num protowire.Number) string {
+	if v := f.bytes[num]; len(v) > 0 {
+		return string(v[len(v)-1])
+	}
+	return ""
+}
+
+func (f protoFields) bool(num protowire.Number) bool {
+	v := f.varints[num]
+	return len(v) > 0 && v[len(v)-1] != 0
+}
+
+func (f protoFields) enum(num protowire.Number, names []string) string {
+	v := f.varints[num]
+	if len(v) == 0 {
+		return ""
+	}
+	if i := v[len(v)-1]; i < uint64(len(names)) {
+		return names[i]
+	}
+	return fmt.Sprint(v[len(v)-1])
+}
+
+// each decodes every message in the repeated field num.
+func (f protoFields) each(num protowire.Number, fn func(protoFields)) error {
+	for _, b := range f.bytes[num] {
+		sub, err := decodeProtoFields(b)
+		if err != nil {
+			return err
+		}
+		fn(sub)
+	}
+	return nil
+}
+
+func decodeStardocAttributes(f protoFields, num protowire.Number) ([]stardocAttribute, error) {
+	attrs := []stardocAttribute{}
+	err := f.each(num, func(a protoFields) {
+		attrs = append(attrs, stardocAttribute{
+			Name:         a.string(1),
+			DocString:    a.string(2),
+			Type:         a.enum(3, stardocAttributeTypes),
+			Mandatory:    a.bool(4),
+			DefaultValue: a.string(6),
+		})
+	})
+	return attrs, err
+}
+
+// decodeStardocProto decodes a binary ModuleInfo message.
+func decodeStardocProto(b []byte) (stardocModule, error) {
+	var m stardocModule
+	f, err := decodeProtoFields(b)
+	if err != nil {
+		return m, err
+	}
+	m.ModuleDocstring = f.string(5)
+	m.File = f.string(6)
+
+	var errs []string
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	rules := func(num protowire.Number) []stardocRule {
+		rules := []stardocRule{}
+		collect(f.each(num, func(r protoFields) {
+			attrs, err := decodeStardocAttributes(r, 3)
+			collect(err)
+			rules = append(rules, stardocRule{RuleName: r.string(1), DocString: r.string(2), Attribute: attrs})
+		}))
+		return rules
+	}
+	m.RuleInfo = rules(1)
+	m.RepositoryRuleInfo = rules(8)
+	collect(f.each(2, func(p protoFields) {
+		provider := stardocProvider{ProviderName: p.string(1), DocString: p.string(2)}
+		collect(p.each(3, func(field protoFields) {
+			provider.FieldInfo = append(provider.FieldInfo, stardocProviderField{Name: field.string(1), DocString: field.string(2)})
+		}))
+		m.ProviderInfo = append(m.ProviderInfo, provider)
+	}))
+	collect(f.each(3, func(fn protoFields) {
+		function := stardocFunction{FunctionName: fn.string(1), DocString: fn.string(3)}
+		collect(fn.each(2, func(p protoFields) {
+			function.Parameter = append(function.Parameter, stardocParameter{
+				Name:         p.string(1),
+				DocString:    p.string(2),
+				DefaultValue: p.string(3),
+				Mandatory:    p.bool(4),
+			})
+		}))
+		m.FuncInfo = append(m.FuncInfo, function)
+	}))
+	collect(f.each(4, func(a protoFields) {
+		attrs, err := decodeStardocAttributes(a, 4)
+		collect(err)
+		m.AspectInfo = append(m.AspectInfo, stardocAspect{AspectName: a.string(1), DocString: a.string(2), Attribute: attrs})
+	}))
+	collect(f.each(9, func(mac protoFields) {
+		attrs, err := decodeStardocAttributes(mac, 3)
+		collect(err)
+		m.MacroInfo = append(m.MacroInfo, stardocMacro{MacroName: mac.string(1), DocString: mac.string(2), Attribute: attrs})
+	}))
+
+	if len(errs) > 0 {
+		return m, fmt.Errorf("invalid ModuleInfo: %s", strings.Join(errs, "; "))
+	}
+	return m, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Helpers for writing messages the way protoc does, so fixtures read like
+// the .proto definitions they follow.
+
+func protoString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func protoMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func protoVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// stardocAttributeInfo writes an AttributeInfo: name = 1, doc_string = 2,
+// type = 3, mandatory = 4, default_value = 6.
+func stardocAttributeInfo(name, doc string, typ uint64, mandatory bool, def string) []byte {
+	b := protoString(nil, 1, name)
+	b = protoString(b, 2, doc)
+	b = protoVarint(b, 3, typ)
+	if mandatory {
+		b = protoVarint(b, 4, 1)
+	}
+	if len(def) > 0 {
+		b = protoString(b, 6, def)
+	}
+	return b
+}
+
+// stardocFixture is a ModuleInfo with one symbol of each kind dashing reads,
+// plus a module_extension_info (7) that it skips.
+func stardocFixture() []byte {
+	rule := protoString(nil, 1, "go_library")
+	rule = protoString(rule, 2, "Builds a Go library.")
+	rule = protoMessage(rule, 3, stardocAttributeInfo("name", "A unique name for this target.", 1, true, ""))
+	rule = protoMessage(rule, 3, stardocAttributeInfo("srcs", "Source files.", 7, false, "[]"))
+	rule = protoString(rule, 4, "//go:def.bzl%go_library")
+	rule = protoVarint(rule, 6, 0)
+
+	provider := protoString(nil, 1, "GoInfo")
+	provider = protoString(provider, 2, "Information about a Go target.")
+	provider = protoMessage(provider, 3, protoMessage(protoString(nil, 1, "importpath"), 2, []byte("The import path.")))
+
+	function := protoString(nil, 1, "go_context")
+	function = protoMessage(function, 2, protoString(protoString(nil, 1, "ctx"), 2, "The rule context."))
+	param := protoString(nil, 1, "attr")
+	param = protoString(param, 2, "Attributes to use.")
+	param = protoString(param, 3, "None")
+	function = protoMessage(function, 2, param)
+	function = protoString(function, 3, "Returns the Go context.")
+
+	aspect := protoString(nil, 1, "go_archive_aspect")
+	aspect = protoString(aspect, 2, "Collects archives.")
+	aspect = protoString(aspect, 3, "deps")
+	aspect = protoMessage(aspect, 4, stardocAttributeInfo("mode", "", 4, false, `"auto"`))
+
+	repo := protoString(nil, 1, "go_repository")
+	repo = protoMessage(repo, 3, stardocAttributeInfo("importpath", "", 4, true, ""))
+
+	macro := protoString(nil, 1, "go_binary_macro")
+	macro = protoString(macro, 2, "Wraps go_binary.")
+	macro = protoMessage(macro, 3, stardocAttributeInfo("embed", "", 7, false, "[]"))
+
+	b := protoMessage(nil, 1, rule)
+	b = protoMessage(b, 2, provider)
+	b = protoMessage(b, 3, function)
+	b = protoMessage(b, 4, aspect)
+	b = protoString(b, 5, "Rules for Go.")
+	b = protoString(b, 6, "//go:def.bzl")
+	b = protoMessage(b, 7, protoString(nil, 1, "go_sdk"))
+	b = protoMessage(b, 8, repo)
+	return protoMessage(b, 9, macro)
+}
+
+// stardocFixtureJSON is stardocFixture in the proto3 JSON mapping.
+const stardocFixtureJSON = `{
+  "ruleInfo": [{
+    "ruleName": "go_library",
+    "docString": "Builds a Go library.",
+    "attribute": [
+      {"name": "name", "docString": "A unique name for this target.", "type": "NAME", "mandatory": true},
+      {"name": "srcs", "docString": "Source files.", "type": "LABEL_LIST", "defaultValue": "[]"}
+    ],
+    "originKey": {"name": "go_library", "file": "//go:def.bzl"}
+  }],
+  "providerInfo": [{
+    "providerName": "GoInfo",
+    "docString": "Information about a Go target.",
+    "fieldInfo": [{"name": "importpath", "docString": "The import path."}]
+  }],
+  "funcInfo": [{
+    "functionName": "go_context",
+    "parameter": [
+      {"name": "ctx", "docString": "The rule context."},
+      {"name": "attr", "docString": "Attributes to use.", "defaultValue": "None"}
+    ],
+    "docString": "Returns the Go context."
+  }],
+  "aspectInfo": [{
+    "aspectName": "go_archive_aspect",
+    "docString": "Collects archives.",
+    "aspectAttribute": ["deps"],
+    "attribute": [{"name": "mode", "type": "STRING", "defaultValue": "\"auto\""}]
+  }],
+  "moduleDocstring": "Rules for Go.",
+  "file": "//go:def.bzl",
+  "moduleExtensionInfo": [{"extensionName": "go_sdk"}],
+  "repositoryRuleInfo": [{
+    "ruleName": "go_repository",
+    "attribute": [{"name": "importpath", "type": "STRING", "mandatory": true}]
+  }],
+  "macroInfo": [{
+    "macroName": "go_binary_macro",
+    "docString": "Wraps go_binary.",
+    "attribute": [{"name": "embed", "type": "LABEL_LIST", "defaultValue": "[]"}]
+  }]
+}`
+
+func TestDecodeStardocProto(t *testing.T) {
+	m, err := decodeStardocProto(stardocFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := decodeStardocJSON([]byte(stardocFixtureJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("decodeStardocProto() =\n%+v\nwant\n%+v", m, want)
+	}
+	if got := m.RuleInfo[0].Attribute[1].Type; got != "LABEL_LIST" {
+		t.Errorf("srcs type = %q, want LABEL_LIST", got)
+	}
+}
+
+func TestDecodeStardocProtoInvalid(t *testing.T) {
+	b := stardocFixture()
+	if _, err := decodeStardocProto(b[:len(b)-1]); err == nil {
+		t.Error("decodeStardocProto() of a truncated message succeeded")
+	}
+	// A valid outer message whose rule_info isn't a message.
+	if _, err := decodeStardocProto(protoString(nil, 1, "\xff")); err == nil {
+		t.Error("decodeStardocProto() of a malformed rule_info succeeded")
+	}
+}
+
+func TestDecodeProtoFields(t *testing.T) {
+	b := protoVarint(nil, 1, 150)
+	b = protoString(b, 2, "a")
+	b = protoString(b, 2, "b")
+	b = protoVarint(b, 3, 99)
+	f, err := decodeProtoFields(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.varints[1]; !reflect.DeepEqual(got, []uint64{150}) {
+		t.Errorf("varints[1] = %v, want [150]", got)
+	}
+	if got := f.string(2); got != "b" {
+		t.Errorf("string(2) = %q, want the last value b", got)
+	}
+	if got := f.enum(3, []string{"ZERO"}); got != "99" {
+		t.Errorf("enum(3) = %q, want the number of an unknown value", got)
+	}
+	if got := f.string(4); got != "" {
+		t.Errorf("string(4) = %q, want empty for a missing field", got)
+	}
+}
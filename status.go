@@ -0,0 +1,71 @@
+package main
+
+import (
+	"golang.org/x/net/html"
+)
+
+// Status tags the entries of a selector that are deprecated, experimental and
+// so on. Every condition that is set must hold for the status to apply.
+type Status struct {
+	Label     string           `yaml:"label"`                // Shown in front of the description in Dash's menu, e.g. "Deprecated"
+	NameRegex *regexpYaml      `yaml:"name_regex,omitempty"` // The entry name matches this regexp, e.g. ^--(no)?experimental_
+	TextRegex *regexpYaml      `yaml:"text_regex,omitempty"` // The element's text or its description matches this regexp
+	Css       *cssSelectorYaml `yaml:"css,omitempty"`        // The element, an ancestor or the description matches this selector
+	Type      string           `yaml:"type,omitempty"`       // Index the entry under this type instead
+	Suffix    string           `yaml:"suffix,omitempty"`     // Append this to the entry name
+	Exclude   bool             `yaml:"exclude,omitempty"`    // Leave the entry out of the index
+}
+
+// matches reports whether the status applies to the entry named name for
+// node n, whose description element (if any) is d.
+func (s *Status) matches(name string, n, d *html.Node, textString string) bool {
+	if s.NameRegex == nil && s.TextRegex == nil && s.Css == nil {
+		return false
+	}
+	if s.NameRegex != nil && !s.NameRegex.MatchString(name) {
+		return false
+	}
+	if s.TextRegex != nil && !s.TextRegex.MatchString(textString) && (d == nil || !s.TextRegex.MatchString(text(d))) {
+		return false
+	}
+	if s.Css != nil && !s.Css.Match(n) && closest(n, s.Css) == nil && (d == nil || !matchesWithin(d, s.Css)) {
+		return false
+	}
+	return true
+}
+
+// matchesWithin reports whether n or one of its descendants matches sel.
+func matchesWithin(n *html.Node, sel *cssSelectorYaml) bool {
+	if sel.Match(n) {
+		return true
+	}
+	for c := range n.Descendants() {
+		if c.Type == html.ElementNode && sel.Match(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyStatuses tags ref with every status that matches it. It returns false
+// if one of them excludes the entry.
+func (t *Transform) applyStatuses(ref *reference, n, d *html.Node, textString string) bool {
+	name := ref.name
+	for i := range t.Statuses {
+		s := &t.Statuses[i]
+		if !s.matches(name, n, d, textString) {
+			continue
+		}
+		if s.Exclude {
+			return false
+		}
+		if len(s.Label) > 0 {
+			ref.labels = append(ref.labels, s.Label)
+		}
+		if len(s.Type) > 0 {
+			ref.etype = s.Type
+		}
+		ref.name += s.Suffix
+	}
+	return true
+}
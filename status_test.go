@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	css "github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+func TestApplyStatuses(t *testing.T) {
+	sel := Transform{Statuses: []Status{
+		{Label: "Experimental", NameRegex: testRegexp(`^--(no)?experimental_`)},
+		{Label: "Deprecated", TextRegex: testRegexp(`(?i)\bdeprecated\b`)},
+		{Label: "Legacy", Css: testSelector(".legacy"), Type: "Field", Suffix: " (legacy)"},
+		{Exclude: true, NameRegex: testRegexp(`^--internal_`)},
+	}}
+	top := parseTestHTML(t, `<dl class="legacy"><dt id="a">--old</dt><dd><p>Deprecated.</p></dd></dl><dt id="b">--x</dt>`)
+	dt := css.MustCompile("#a").MatchFirst(top)
+	dd := css.MustCompile("dd").MatchFirst(top)
+	plain := css.MustCompile("#b").MatchFirst(top)
+
+	for _, test := range []struct {
+		name     string
+		legacy   bool
+		indexed  bool
+		labels   []string
+		etype    string
+		wantName string
+	}{
+		{"--experimental_foo", false, true, []string{"Experimental"}, "Option", "--experimental_foo"},
+		{"--noexperimental_foo", false, true, []string{"Experimental"}, "Option", "--noexperimental_foo"},
+		{"--old", true, true, []string{"Deprecated", "Legacy"}, "Field", "--old (legacy)"},
+		{"--x", false, true, nil, "Option", "--x"},
+		{"--internal_x", false, false, nil, "Option", "--internal_x"},
+	} {
+		n, d, textString := plain, (*html.Node)(nil), "--x"
+		if test.legacy {
+			n, d, textString = dt, dd, "--old"
+		}
+		ref := &reference{name: test.name, etype: "Option"}
+		if got := sel.applyStatuses(ref, n, d, textString); got != test.indexed {
+			t.Errorf("%s: applyStatuses() = %v, want %v", test.name, got, test.indexed)
+			continue
+		}
+		if !test.indexed {
+			continue
+		}
+		if !reflect.DeepEqual(ref.labels, test.labels) || ref.etype != test.etype || ref.name != test.wantName {
+			t.Errorf("%s: ref = %q %s %v, want %q %s %v", test.name, ref.name, ref.etype, ref.labels, test.wantName, test.etype, test.labels)
+		}
+	}
+}
+
+func TestExcludedEntriesStayOutOfTOC(t *testing.T) {
+	top := parseTestHTML(t, `<html><head></head><body><dl>
+<dt><a id="flag--keep_going">--keep_going</a></dt>
+<dt><a id="flag--experimental_foo">--experimental_foo</a></dt>
+</dl></body></html>`)
+	head := css.MustCompile("head").MatchFirst(top)
+	d := Dashing{Selectors: []Transform{{
+		CssSelector: *testSelector("dt a"),
+		Type:        "Option",
+		Statuses:    []Status{{Exclude: true, NameRegex: testRegexp(`^--experimental_`)}},
+	}}}
+	refs := d.selectorRefs(top, "cmd.html", head, map[*html.Node]bool{})
+	if len(refs) != 1 || refs[0].name != "--keep_going" {
+		t.Errorf("selectorRefs() = %v, want only --keep_going", refs)
+	}
+	var anchors []string
+	for _, a := range css.MustCompile("a.dashAnchor").MatchAll(top) {
+		anchors = append(anchors, attr(a, "name"))
+	}
+	if len(anchors) != 1 || !strings.Contains(anchors[0], "keep_going") {
+		t.Errorf("TOC anchors = %q, want only --keep_going", anchors)
+	}
+}
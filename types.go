@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dashEntryTypes are the entry types Dash has icons for.
+// See https://kapeli.com/docsets#supportedentrytypes
+var dashEntryTypes = []string{
+	"Annotation", "Attribute", "Binding", "Builtin", "Callback", "Category",
+	"Class", "Command", "Component", "Constant", "Constructor", "Define",
+	"Delegate", "Diagram", "Directive", "Element", "Entry", "Enum",
+	"Environment", "Error", "Event", "Exception", "Extension", "Field", "File",
+	"Filter", "Framework", "Function", "Global", "Guide", "Hook", "Instance",
+	"Instruction", "Interface", "Keyword", "Library", "Literal", "Macro",
+	"Method", "Mixin", "Modifier", "Module", "Namespace", "Notation", "Object",
+	"Operator", "Option", "Package", "Parameter", "Plugin", "Procedure",
+	"Property", "Protocol", "Provider", "Provisioner", "Query", "Record",
+	"Resource", "Sample", "Section", "Service", "Setting", "Shortcut",
+	"Statement", "Struct", "Style", "Subroutine", "Tag", "Test", "Trait",
+	"Type", "Union", "Value", "Variable", "Word",
+}
+
+func isDashEntryType(t string) bool {
+	for _, known := range dashEntryTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveType maps a configured type through TypeAliases.
+func (d *Dashing) resolveType(t string) string {
+	if alias, ok := d.TypeAliases[t]; ok {
+		return alias
+	}
+	return t
+}
+
+// resolveTypes replaces type aliases in every selector and checks that all
+// types are ones Dash supports.
+func (d *Dashing) resolveTypes() error {
+	problems := []string{}
+	for alias, t := range d.TypeAliases {
+		if !isDashEntryType(t) {
+			problems = append(problems, fmt.Sprintf("type_aliases: %s maps to %s", alias, unknownType(t)))
+		}
+	}
+
+	check := func(section string, selectors []Transform) {
+		for i := range selectors {
+			sel := &selectors[i]
+			sel.Type = d.resolveType(sel.Type)
+			if !isDashEntryType(sel.Type) {
+				problems = append(problems, fmt.Sprintf("%s[%d] (%s): %s", section, i, sel.CssSelector.raw, unknownType(sel.Type)))
+			}
+			for j := range sel.Statuses {
+				s := &sel.Statuses[j]
+				if len(s.Type) == 0 {
+					continue
+				}
+				s.Type = d.resolveType(s.Type)
+				if !isDashEntryType(s.Type) {
+					problems = append(problems, fmt.Sprintf("%s[%d] (%s) statuses[%d]: %s", section, i, sel.CssSelector.raw, j, unknownType(s.Type)))
+				}
+			}
+		}
+	}
+	check("selectors", d.Selectors)
+	check("backup_selectors", d.BackupSelectors)
+	for i, s := range d.Sources {
+		check(fmt.Sprintf("sources[%d].selectors", i), s.Selectors)
+		check(fmt.Sprintf("sources[%d].backup_selectors", i), s.BackupSelectors)
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid entry types:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// unknownType describes an unsupported type, suggesting the closest known ones.
+func unknownType(t string) string {
+	suggestions := []string{}
+	for _, known := range dashEntryTypes {
+		if strings.EqualFold(t, known) || levenshtein(strings.ToLower(t), strings.ToLower(known)) <= 2 {
+			suggestions = append(suggestions, known)
+		}
+	}
+	if len(suggestions) == 0 {
+		return fmt.Sprintf("unknown type '%s'", t)
+	}
+	return fmt.Sprintf("unknown type '%s' (did you mean %s?)", t, strings.Join(suggestions, " or "))
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
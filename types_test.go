@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveTypes(t *testing.T) {
+	d := Dashing{
+		TypeAliases: map[string]string{"Rule": "Class", "Flag": "Option"},
+		Selectors: []Transform{
+			{CssSelector: *testSelector("h2"), Type: "Rule"},
+			{CssSelector: *testSelector("dt a"), Type: "Flag", Statuses: []Status{{Label: "Deprecated", Type: "Rule"}}},
+			{CssSelector: *testSelector("h3"), Type: "Section"},
+		},
+	}
+	if err := d.resolveTypes(); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"Class", "Option", "Section"} {
+		if got := d.Selectors[i].Type; got != want {
+			t.Errorf("selectors[%d].type = %q, want %q", i, got, want)
+		}
+	}
+	if got := d.Selectors[1].Statuses[0].Type; got != "Class" {
+		t.Errorf("statuses[0].type = %q, want Class", got)
+	}
+
+	d = Dashing{
+		TypeAliases: map[string]string{"Bad": "Rule"},
+		Selectors:   []Transform{{CssSelector: *testSelector("h2"), Type: "Secton"}},
+		Sources:     []Source{{BackupSelectors: []Transform{{CssSelector: *testSelector("h3"), Type: "Banana"}}}},
+	}
+	err := d.resolveTypes()
+	if err == nil {
+		t.Fatal("resolveTypes() succeeded with unknown types")
+	}
+	for _, want := range []string{
+		"type_aliases: Bad maps to unknown type 'Rule'",
+		"selectors[0] (h2): unknown type 'Secton' (did you mean Section?)",
+		"sources[0].backup_selectors[0] (h3): unknown type 'Banana'",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("resolveTypes() = %q, want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestUnknownType(t *testing.T) {
+	for _, test := range []struct {
+		t, want string
+	}{
+		{"option", "unknown type 'option' (did you mean Option?)"},
+		{"Metod", "unknown type 'Metod' (did you mean Method?)"},
+		{"Banana", "unknown type 'Banana'"},
+	} {
+		if got := unknownType(test.t); got != test.want {
+			t.Errorf("unknownType(%q) = %q, want %q", test.t, got, test.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"--keep_going", "--keep_going", 0},
+		{"--keep_goign", "--keep_going", 2},
+		{"kitten", "sitting", 3},
+	} {
+		if got := levenshtein(test.a, test.b); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	css "github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// Output profiles for `build --profile`.
+const (
+	profileDash = "dash"
+	// profileZeal makes a docset that also works in Zeal, which ignores
+	// isJavaScriptEnabled and reads its metadata from meta.json.
+	profileZeal = "zeal"
+)
+
+// applyProfile adjusts the config for building with the given profile.
+func (d *Dashing) applyProfile(profile string) error {
+	switch profile {
+	case "", profileDash:
+	case profileZeal:
+		// Pages have their scripts removed, so they look the same whether or
+		// not the viewer runs JavaScript.
+		d.AllowJS = false
+	default:
+		return fmt.Errorf("unknown profile '%s' (expected %s or %s)", profile, profileDash, profileZeal)
+	}
+	d.profile = profile
+	return nil
+}
+
+// stripScripts removes the scripts from a page and unwraps its <noscript>
+// elements.
+func stripScripts(top *html.Node) {
+	for _, n := range css.MustCompile("script").MatchAll(top) {
+		n.Parent.RemoveChild(n)
+	}
+	for _, n := range css.MustCompile("noscript").MatchAll(top) {
+		// The parser keeps <noscript> contents as text when scripting is on.
+		if n.FirstChild != nil && n.FirstChild == n.LastChild && n.FirstChild.Type == html.TextNode {
+			if nodes, err := html.ParseFragment(strings.NewReader(n.FirstChild.Data), n.Parent); err == nil {
+				n.RemoveChild(n.FirstChild)
+				for _, c := range nodes {
+					n.AppendChild(c)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = n.FirstChild {
+			n.RemoveChild(c)
+			n.Parent.InsertBefore(c, n)
+		}
+		n.Parent.RemoveChild(n)
+	}
+}
+
+// scriptRendered lists the empty custom elements left on a page, such as
+// <devsite-video>. Scripts fill them in, so without the scripts Zeal shows
+// nothing where the Dash build shows their content.
+func scriptRendered(top *html.Node) []string {
+	elements := []string{}
+	for n := range top.Descendants() {
+		if n.Type != html.ElementNode || !strings.Contains(n.Data, "-") || slices.Contains(elements, n.Data) {
+			continue
+		}
+		empty := true
+		for c := n.FirstChild; c != nil && empty; c = c.NextSibling {
+			empty = c.Type != html.ElementNode && (c.Type != html.TextNode || len(strings.TrimSpace(c.Data)) == 0)
+		}
+		if empty {
+			elements = append(elements, n.Data)
+		}
+	}
+	sort.Strings(elements)
+	return elements
+}
+
+// zealMeta is the meta.json Zeal reads from the root of a docset.
+type zealMeta struct {
+	Name     string `json:"name"`
+	Title    string `json:"title"`
+	Version  string `json:"version,omitempty"`
+	Revision string `json:"revision"`
+}
+
+// writeZealMeta writes meta.json and checks that the icons are where Zeal
+// looks for them.
+func writeZealMeta(dashing Dashing, writer fileWriter) error {
+	title := dashing.Name
+	if len(title) == 0 {
+		title = capitalize(dashing.Package)
+	}
+	revision := dashing.Revision
+	if len(revision) == 0 {
+		revision = "0"
+	}
+	meta, err := json.MarshalIndent(zealMeta{
+		Name:     dashing.Package,
+		Title:    title,
+		Version:  dashing.docsetVersion,
+		Revision: revision,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	for _, icon := range []string{"icon.png", "icon@2x.png"} {
+		if _, err := os.Stat(filepath.Join(writer.destRoot, icon)); err != nil {
+			fmt.Printf("Warning: the docset has no %s; Zeal will show a generic icon\n", icon)
+		}
+	}
+	return writer.WriteFile("meta.json", append(meta, '\n'), 0644)
+}
+
+// checkIndexPaths checks that every path in the search index, with its
+// <dash_entry_*> metadata removed as Zeal does, names a page in the docset
+// and an anchor on it. It returns the problems found.
+func checkIndexPaths(db *sql.DB, documents string) ([]string, error) {
+	rows, err := db.Query(`SELECT name, path FROM searchIndex ORDER BY path, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	anchors := map[string]map[string]bool{}
+	problems := []string{}
+	for rows.Next() {
+		var name, indexPath string
+		if err := rows.Scan(&name, &indexPath); err != nil {
+			return nil, err
+		}
+		stripped := dashPathRegexp.ReplaceAllString(indexPath, "")
+		if strings.Contains(stripped, "<dash_entry_") {
+			problems = append(problems, fmt.Sprintf("'%s': malformed entry metadata in %s", name, indexPath))
+			continue
+		}
+		u, err := url.Parse(stripped)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("'%s': %s", name, err))
+			continue
+		}
+		page := filepath.Join(documents, filepath.FromSlash(u.Path))
+		ids, ok := anchors[page]
+		if !ok {
+			ids = pageAnchors(page)
+			anchors[page] = ids
+		}
+		if ids == nil {
+			problems = append(problems, fmt.Sprintf("'%s': %s does not exist", name, u.Path))
+			continue
+		}
+		if len(u.Fragment) > 0 && !strings.HasPrefix(u.Fragment, "//dash_ref") && !ids[u.Fragment] {
+			problems = append(problems, fmt.Sprintf("'%s': %s has no anchor '%s'", name, u.Path, u.Fragment))
+		}
+	}
+	return problems, rows.Err()
+}
+
+// pageAnchors returns the ids and anchor names on the page at src, or nil
+// if it can't be read.
+func pageAnchors(src string) map[string]bool {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	top, err := html.Parse(f)
+	if err != nil {
+		return nil
+	}
+	ids := map[string]bool{}
+	for n := range top.Descendants() {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		if id := attr(n, "id"); len(id) > 0 {
+			ids[id] = true
+		}
+		if n.Data == "a" && len(attr(n, "name")) > 0 {
+			ids[attr(n, "name")] = true
+		}
+	}
+	return ids
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	css "github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+func TestCapitalize(t *testing.T) {
+	for _, test := range []struct {
+		s, want string
+	}{
+		{"bazel", "Bazel"},
+		{"rules_go", "Rules_go"},
+		{"élan", "Élan"},
+		{"Bazel", "Bazel"},
+		{"", ""},
+	} {
+		if got := capitalize(test.s); got != test.want {
+			t.Errorf("capitalize(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestStripScripts(t *testing.T) {
+	for _, test := range []struct {
+		page, want string
+	}{
+		{`<p>a</p><script>document.write("b")</script>`, `<p>a</p>`},
+		{`<p>c</p><noscript><p>enable JavaScript</p></noscript>`, `<p>c</p><p>enable JavaScript</p>`},
+		{`<div><noscript><img src="x.png"></noscript></div>`, `<div><img src="x.png"/></div>`},
+	} {
+		top := parseTestHTML(t, test.page)
+		stripScripts(top)
+		var b strings.Builder
+		for n := css.MustCompile("body").MatchFirst(top).FirstChild; n != nil; n = n.NextSibling {
+			html.Render(&b, n)
+		}
+		if b.String() != test.want {
+			t.Errorf("stripScripts(%q) = %q, want %q", test.page, b.String(), test.want)
+		}
+	}
+}
+
+func TestScriptRendered(t *testing.T) {
+	for _, test := range []struct {
+		page string
+		want []string
+	}{
+		{`<devsite-content><p>text</p></devsite-content>`, []string{}},
+		{`<devsite-selector><section>tab</section></devsite-selector>`, []string{}},
+		{`<devsite-video video-id="x"></devsite-video><devsite-video> </devsite-video>`, []string{"devsite-video"}},
+		{`<devsite-iframe></devsite-iframe><devsite-content><devsite-feedback/></devsite-content>`, []string{"devsite-feedback", "devsite-iframe"}},
+	} {
+		got := scriptRendered(parseTestHTML(t, test.page))
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("scriptRendered(%q) = %q, want %q", test.page, got, test.want)
+		}
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	d := Dashing{AllowJS: true}
+	if err := d.applyProfile(profileZeal); err != nil || d.AllowJS || d.profile != profileZeal {
+		t.Errorf("applyProfile(zeal) = %v, AllowJS %v, profile %q", err, d.AllowJS, d.profile)
+	}
+	d = Dashing{AllowJS: true}
+	if err := d.applyProfile(""); err != nil || !d.AllowJS {
+		t.Errorf("applyProfile(\"\") = %v, AllowJS %v", err, d.AllowJS)
+	}
+	if err := d.applyProfile("kapeli"); err == nil {
+		t.Errorf("applyProfile(kapeli) = nil, want an error")
+	}
+}
+
+func TestWriteZealMeta(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		config Dashing
+		want   zealMeta
+	}{
+		{"defaults", Dashing{Package: "bazel"}, zealMeta{Name: "bazel", Title: "Bazel", Revision: "0"}},
+		{"configured", Dashing{Name: "Bazel Docs", Package: "bazel", Revision: "2", docsetVersion: "8.0.0"}, zealMeta{Name: "bazel", Title: "Bazel Docs", Version: "8.0.0", Revision: "2"}},
+	} {
+		dir := t.TempDir()
+		if err := writeZealMeta(test.config, fileWriter{dir}); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(filepath.Join(dir, "meta.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got zealMeta
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%s: meta.json = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckIndexPaths(t *testing.T) {
+	pages := map[string]string{
+		"a.html":     `<h2 id="intro">Intro</h2><a name="legacy"></a>`,
+		"sub/b.html": `<p id="x">x</p>`,
+	}
+	for _, test := range []struct {
+		name, path, want string
+	}{
+		{"page", "a.html", ""},
+		{"id", "a.html#intro", ""},
+		{"anchor name", "a.html#legacy", ""},
+		{"dash anchor", "a.html#//dash_ref_1/Section/Intro/0", ""},
+		{"metadata", "<dash_entry_name=x><dash_entry_menuDescription=[Deprecated] d>sub/b.html#x", ""},
+		{"missing page", "c.html#intro", "'missing page': c.html does not exist"},
+		{"missing anchor", "sub/b.html#y", "'missing anchor': sub/b.html has no anchor 'y'"},
+		{"malformed metadata", "a.html<dash_entry_name=x>", "'malformed metadata': malformed entry metadata in a.html<dash_entry_name=x>"},
+		{"bad URL", "%zz.html", `'bad URL': parse "%zz.html": invalid URL escape "%zz"`},
+	} {
+		docset := writeDocset(t, t.TempDir(), pages, [][3]string{{test.name, "Section", test.path}})
+		db, err := openIndex(docset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		problems, err := checkIndexPaths(db, filepath.Join(docset, "Contents/Resources/Documents"))
+		db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(problems, "\n"); got != test.want {
+			t.Errorf("%s: checkIndexPaths(%q) = %q, want %q", test.name, test.path, got, test.want)
+		}
+	}
+}